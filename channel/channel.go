@@ -2,16 +2,43 @@ package channel
 
 import (
 	"context"
+	"fmt"
 	"github.com/rs/zerolog"
+	"go.dedis.ch/debugtools/internal/goroutine"
+	"go.dedis.ch/debugtools/report"
 	"runtime/debug"
+	"sync/atomic"
 	"time"
 )
 
+// defaultChannelTimeout is the timeout of the channels which are not
+// configured with WithTimeout.
 const defaultChannelTimeout = time.Second * 1
 
+// OnTimeout is the action taken by a goroutine which failed to use a channel
+// in time, once the event has been reported. It can be set with the
+// environment variable CRY_ACTION.
+var OnTimeout = report.ActionLog
+
+// DumpDir is the directory where all the goroutines are dumped when a
+// goroutine fails to use a channel in time, in which case the stacks of the
+// goroutines blocked on the channel are added to the event. It can be set
+// with the environment variable CRY_DUMP, and dumping is disabled when empty.
+var DumpDir = ""
+
+// HistorySize is the default number of sends and receives each Timed channel
+// remembers to add to its timeout reports. It can be overridden per channel
+// with WithHistory. Since the channels are not only meant for debugging,
+// the history is disabled by default.
+var HistorySize = 0
+
 type Timed[T any] struct {
-	c   chan T
-	log zerolog.Logger
+	c       chan T
+	name    string
+	timeout time.Duration
+	log     zerolog.Logger
+	cfg     config
+	monitor *monitor
 }
 
 type Error string
@@ -25,11 +52,31 @@ func (e Error) Error() string {
 	return string(e)
 }
 
-// WithExpiration creates a new channel of the given size and type
-func WithExpiration[T any](bufSize int) Timed[T] {
+// WithExpiration creates a new channel of the given size and type,
+// configured with the given options.
+func WithExpiration[T any](bufSize int, opts ...Option) Timed[T] {
+	cfg := newConfig(opts)
+	c := make(chan T, bufSize)
+
+	name := cfg.name
+	if name == "" {
+		name = fmt.Sprintf("Timed#%d", atomic.AddUint64(&lastChannelID, 1))
+	}
+
+	m := newMonitor(name, func() string {
+		return fmt.Sprintf("%d/%d elements", len(c), cap(c))
+	}, cfg.history)
+	statistics.add(m, cfg.name, func() (int, int) {
+		return len(c), cap(c)
+	})
+
 	return Timed[T]{
-		c:   make(chan T, bufSize),
-		log: Logger.With().Int("size", bufSize).Logger(),
+		c:       c,
+		name:    name,
+		timeout: cfg.timeout,
+		log:     cfg.logger.With().Str("name", name).Int("size", bufSize).Logger(),
+		cfg:     cfg,
+		monitor: m,
 	}
 }
 
@@ -39,11 +86,22 @@ func WithExpiration[T any](bufSize int) Timed[T] {
 func (c *Timed[T]) SendWithContext(ctx context.Context, e T) {
 	select {
 	case c.c <- e:
+		c.monitor.record(modeSend)
+		return
+	default:
+	}
+
+	defer c.monitor.wait(modeSend)()
+
+	select {
+	case c.c <- e:
+		c.monitor.record(modeSend)
 		return
 	case <-ctx.Done():
-		c.log.Warn().Msgf("%s %X\n%s", ErrFailedToSend, c.c, string(debug.Stack()))
+		c.timedOut(ErrFailedToSend)
 		c.c <- e
-		c.log.Info().Msgf("unblocked channel %X on send", c.c)
+		c.monitor.record(modeSend)
+		c.log.Info().Msgf("unblocked channel %s on send", c.name)
 	}
 }
 
@@ -58,10 +116,10 @@ func (c *Timed[T]) SendWithTimeout(t time.Duration, e T) {
 }
 
 // Send adds an element in the channel,
-// or logs a warning if it fails after default timeout.
+// or logs a warning if it fails after the channel timeout.
 // Note: this is a blocking call as it waits on a channel.
 func (c *Timed[T]) Send(e T) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultChannelTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
 	defer cancel()
 
 	c.SendWithContext(ctx, e)
@@ -75,10 +133,20 @@ func (c *Timed[T]) ReceiveWithContext(ctx context.Context) T {
 
 	select {
 	case e = <-c.c:
+		c.monitor.record(modeReceive)
+		return e
+	default:
+	}
+
+	defer c.monitor.wait(modeReceive)()
+
+	select {
+	case e = <-c.c:
+		c.monitor.record(modeReceive)
 	case <-ctx.Done():
-		c.log.Warn().Msgf("%s %X\n%s", ErrFailedToReceive, c.c, string(debug.Stack()))
+		c.timedOut(ErrFailedToReceive)
 		c.c <- e
-		c.log.Info().Msgf("unblocked channel %X on receiving", c.c)
+		c.log.Info().Msgf("unblocked channel %s on receiving", c.name)
 	}
 
 	return e
@@ -95,10 +163,10 @@ func (c *Timed[T]) ReceiveWithTimeout(t time.Duration) T {
 }
 
 // Receive removes an element from the channel
-// or logs a warning if it fails after the default timeout.
+// or logs a warning if it fails after the channel timeout.
 // Note: this is a blocking call as it waits on a channel.
 func (c *Timed[T]) Receive() T {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultChannelTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
 	defer cancel()
 
 	return c.ReceiveWithContext(ctx)
@@ -109,6 +177,16 @@ func (c *Timed[T]) Receive() T {
 func (c *Timed[T]) NonBlockingSendWithContext(ctx context.Context, e T) error {
 	select {
 	case c.c <- e:
+		c.monitor.record(modeSend)
+		return nil
+	default:
+	}
+
+	defer c.monitor.wait(modeSend)()
+
+	select {
+	case c.c <- e:
+		c.monitor.record(modeSend)
 		return nil
 	case <-ctx.Done():
 		return ErrFailedToSend
@@ -125,9 +203,9 @@ func (c *Timed[T]) NonBlockingSendWithTimeout(t time.Duration, e T) error {
 }
 
 // NonBlockingSend adds an element in the channel,
-// or returns an error if it fails after the default timeout.
+// or returns an error if it fails after the channel timeout.
 func (c *Timed[T]) NonBlockingSend(e T) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultChannelTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
 	defer cancel()
 
 	return c.NonBlockingSendWithContext(ctx, e)
@@ -140,6 +218,16 @@ func (c *Timed[T]) NonBlockingReceiveWithContext(ctx context.Context) (T, error)
 
 	select {
 	case e = <-c.c:
+		c.monitor.record(modeReceive)
+		return e, nil
+	default:
+	}
+
+	defer c.monitor.wait(modeReceive)()
+
+	select {
+	case e = <-c.c:
+		c.monitor.record(modeReceive)
 		return e, nil
 	case <-ctx.Done():
 		return e, ErrFailedToReceive
@@ -156,9 +244,9 @@ func (c *Timed[T]) NonBlockingReceiveWithTimeout(t time.Duration) (e T, err erro
 }
 
 // NonBlockingReceive removes an element from the channel
-// or returns an error if it fails after the default timeout
+// or returns an error if it fails after the channel timeout
 func (c *Timed[T]) NonBlockingReceive() (e T, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultChannelTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
 	defer cancel()
 
 	return c.NonBlockingReceiveWithContext(ctx)
@@ -173,3 +261,40 @@ func (c *Timed[T]) Len() int {
 func (c *Timed[T]) Channel() chan T {
 	return c.c
 }
+
+// timedOut reports that the calling goroutine failed to use the channel in
+// time, with the given error, and takes the OnTimeout action.
+func (c *Timed[T]) timedOut(err Error) {
+	e := report.Event{
+		Kind:      report.KindTimeout,
+		Severity:  report.SeverityWarning,
+		Primitive: "Timed",
+		Name:      c.name,
+		Message:   fmt.Sprintf("%s %s", err, c.name),
+		Goroutine: goroutine.ID(),
+		Stack:     string(debug.Stack()),
+		History:   c.monitor.history.Records(),
+	}
+
+	statistics.timedOut(c.cfg.name)
+
+	if DumpDir != "" {
+		var dumpErr error
+
+		e, dumpErr = report.WithDump(e, DumpDir, append(c.monitor.goroutines(), e.Goroutine))
+		if dumpErr != nil {
+			c.log.Warn().Err(dumpErr).Msg("failed to dump goroutines")
+		}
+	}
+
+	// the action taken is the one in effect when the timeout is reported
+	action := OnTimeout
+	c.cfg.emit(e)
+
+	switch action {
+	case report.ActionExit:
+		report.Exit(e)
+	case report.ActionPanic:
+		panic(e.String())
+	}
+}
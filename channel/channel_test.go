@@ -3,18 +3,48 @@ package channel
 import (
 	"bytes"
 	"context"
+	"expvar"
+	"fmt"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
+	"go.dedis.ch/debugtools/internal/testutil"
+	"go.dedis.ch/debugtools/registry"
+	"go.dedis.ch/debugtools/report"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
 var originalLogger = Logger
 
+// logBuffer is a buffer which can be written by the blocked goroutines while
+// the tests are reading it.
+type logBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buffer.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buffer.String()
+}
+
 // setupLogger is a helper function to use a testable logger
-func setupLogger() *bytes.Buffer {
-	b := new(bytes.Buffer)
+func setupLogger() *logBuffer {
+	b := new(logBuffer)
 	Logger = zerolog.New(b)
 
 	return b
@@ -268,3 +298,265 @@ func TestLen(t *testing.T) {
 	c.Receive()
 	require.Equal(t, 0, c.Len())
 }
+
+func TestNamedChannel(t *testing.T) {
+	l := setupLogger()
+	defer restoreLogger()
+
+	c := WithExpiration[int](0, WithName("block-queue"))
+
+	go func() {
+		c.SendWithTimeout(time.Millisecond, 0)
+	}()
+
+	// need a looong time on Windows to see the logs in the buffer
+	time.Sleep(time.Millisecond * 100)
+	require.Contains(t, l.String(), `"name":"block-queue"`)
+	require.Contains(t, l.String(), ErrFailedToSend.Error()+" block-queue")
+
+	c.Receive()
+}
+
+func TestChannelOptions(t *testing.T) {
+	l := setupLogger()
+	defer restoreLogger()
+
+	b := new(logBuffer)
+	c := WithExpiration[int](0, WithTimeout(time.Millisecond), WithLogger(zerolog.New(b)))
+
+	start := time.Now()
+	_, err := c.NonBlockingReceive()
+	require.Equal(t, ErrFailedToReceive, err)
+	require.Less(t, time.Since(start), defaultChannelTimeout)
+
+	go func() {
+		c.Send(0)
+	}()
+
+	// need a looong time on Windows to see the logs in the buffer
+	time.Sleep(time.Millisecond * 100)
+	require.Contains(t, b.String(), ErrFailedToSend.Error())
+	require.Empty(t, l.String())
+
+	c.Receive()
+}
+
+func TestChannelReporter(t *testing.T) {
+	l := setupLogger()
+	defer restoreLogger()
+
+	var collector report.Collector
+	c := WithExpiration[int](0, WithName("blocks"), WithReporter(&collector))
+
+	go func() {
+		c.SendWithTimeout(time.Millisecond, 0)
+	}()
+
+	require.Eventually(t, func() bool {
+		return len(collector.Events()) > 0
+	}, time.Second, time.Millisecond)
+	c.Receive()
+
+	events := collector.Events()
+	require.Len(t, events, 1)
+	require.Equal(t, report.KindTimeout, events[0].Kind)
+	require.Equal(t, report.SeverityWarning, events[0].Severity)
+	require.Equal(t, "blocks", events[0].Name)
+	require.Contains(t, events[0].Message, ErrFailedToSend.Error())
+	require.Contains(t, events[0].Stack, "TestChannelReporter")
+	require.Empty(t, l.String())
+}
+
+func TestPanicOnTimeout(t *testing.T) {
+	setupLogger()
+	defer restoreLogger()
+
+	OnTimeout = report.ActionPanic
+	defer func() { OnTimeout = report.ActionLog }()
+
+	c := WithExpiration[int](0, WithName("blocks"), WithTimeout(time.Millisecond))
+
+	defer func() {
+		out := fmt.Sprint(recover())
+		require.Contains(t, out, ErrFailedToSend.Error()+" blocks")
+		require.Contains(t, out, "TestPanicOnTimeout")
+	}()
+
+	c.Send(0)
+}
+
+func TestDumpOnTimeout(t *testing.T) {
+	setupLogger()
+	defer restoreLogger()
+
+	DumpDir = t.TempDir()
+	defer func() { DumpDir = "" }()
+
+	var collector report.Collector
+	c := WithExpiration[int](0, WithReporter(&collector))
+
+	go func() {
+		c.SendWithTimeout(time.Millisecond, 0)
+	}()
+
+	require.Eventually(t, func() bool {
+		return len(collector.Events()) > 0
+	}, time.Second, time.Millisecond)
+	c.Receive()
+
+	e := collector.Events()[0]
+	require.Contains(t, e.Goroutines, "TestDumpOnTimeout.func")
+	require.NotContains(t, e.Goroutines, "testing.tRunner")
+	require.FileExists(t, e.DumpFile)
+}
+
+func statsOf(name string) ChannelStats {
+	for _, s := range Stats() {
+		if s.Name == name {
+			return s
+		}
+	}
+
+	return ChannelStats{Name: name}
+}
+
+func TestChannelStats(t *testing.T) {
+	setupLogger()
+	defer restoreLogger()
+
+	name := testutil.UniqueName(t)
+	c := WithExpiration[int](2, WithName(name), WithTimeout(time.Millisecond))
+	c.Send(0)
+
+	s := statsOf(name)
+	require.Equal(t, 1, s.Len)
+	require.Equal(t, 2, s.Cap)
+
+	c.Send(0)
+	go c.Send(0)
+
+	require.Eventually(t, func() bool {
+		return statsOf(name).Timeouts == 1
+	}, time.Second, time.Millisecond)
+	require.Equal(t, int64(1), statsOf(name).BlockedSenders)
+
+	c.Receive()
+	c.Receive()
+	c.Receive()
+
+	require.Equal(t, 0, statsOf(name).Len)
+	require.Eventually(t, func() bool {
+		return statsOf(name).BlockedSenders == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestChannelStatsForgotten(t *testing.T) {
+	name := testutil.UniqueName(t)
+
+	func() {
+		c := WithExpiration[int](3, WithName(name))
+		c.Send(0)
+	}()
+
+	require.Eventually(t, func() bool {
+		runtime.GC()
+		return statsOf(name).Cap == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestPublishExpvar(t *testing.T) {
+	setupLogger()
+	defer restoreLogger()
+
+	PublishExpvar()
+	PublishExpvar()
+	defer registry.Disable()
+
+	name := testutil.UniqueName(t)
+
+	c := WithExpiration[int](0, WithName(name), WithTimeout(time.Millisecond))
+	go c.Send(0)
+
+	require.Eventually(t, func() bool {
+		return strings.Contains(expvar.Get("debugtools.channel.timeouts").String(), `"`+name+`":1`)
+	}, time.Second, time.Millisecond)
+	require.Contains(t, expvar.Get("debugtools.channel.blocked").String(), `"name":"`+name+`"`)
+
+	c.Receive()
+}
+
+func TestBlockedProfile(t *testing.T) {
+	profiled := func() string {
+		var b strings.Builder
+		_ = pprof.Lookup("debugchan.blocked").WriteTo(&b, 1)
+		return b.String()
+	}
+
+	setupLogger()
+	defer restoreLogger()
+
+	c := WithExpiration[int](0)
+	go func() {
+		c.Send(0)
+	}()
+
+	require.Eventually(t, func() bool {
+		return strings.Contains(profiled(), "TestBlockedProfile.func")
+	}, time.Second, time.Millisecond)
+
+	c.Receive()
+
+	require.Eventually(t, func() bool {
+		return !strings.Contains(profiled(), "TestBlockedProfile.func")
+	}, time.Second, time.Millisecond)
+}
+
+func TestTraceRegions(t *testing.T) {
+	var b bytes.Buffer
+	if err := trace.Start(&b); err != nil {
+		t.Skip("tracing already enabled:", err)
+	}
+
+	c := WithExpiration[int](0, WithName("traced"))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		c.Send(0)
+	}()
+	c.Receive()
+
+	trace.Stop()
+
+	require.Contains(t, b.String(), "Timed traced receive")
+}
+
+func TestHistory(t *testing.T) {
+	setupLogger()
+	defer restoreLogger()
+
+	var collector report.Collector
+	c := WithExpiration[int](1, WithReporter(&collector), WithHistory(2))
+
+	c.Send(1)
+	c.Receive()
+	c.Send(2)
+
+	go func() {
+		c.SendWithTimeout(time.Millisecond, 3)
+	}()
+
+	require.Eventually(t, func() bool {
+		return len(collector.Events()) > 0
+	}, time.Second, time.Millisecond)
+	c.Receive()
+	c.Receive()
+
+	history := collector.Events()[0].History
+	require.Len(t, history, 2)
+	require.Equal(t, modeReceive, history[0].Action)
+	require.Equal(t, modeSend, history[1].Action)
+	require.Contains(t, history[1].Site, "TestHistory")
+
+	disabled := WithExpiration[int](1)
+	disabled.Send(1)
+	require.Empty(t, disabled.monitor.history.Records())
+}
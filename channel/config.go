@@ -0,0 +1,92 @@
+package channel
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.dedis.ch/debugtools/report"
+)
+
+// Reporter receives the events of the channels which are not configured with
+// WithReporter. When nil, the events are logged with the logger of the
+// channel.
+var Reporter report.Reporter
+
+// Option configures a Timed channel created with WithExpiration.
+type Option func(*config)
+
+// config is the per-instance configuration of a Timed channel.
+type config struct {
+	name     string
+	timeout  time.Duration
+	logger   *zerolog.Logger
+	reporter report.Reporter
+	history  int
+}
+
+// WithName gives a name to the channel, used in every log line and report
+// about it instead of a generated one.
+func WithName(name string) Option {
+	return func(c *config) {
+		c.name = name
+	}
+}
+
+// WithTimeout overrides the default timeout used by Send, Receive,
+// NonBlockingSend and NonBlockingReceive.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.timeout = timeout
+	}
+}
+
+// WithLogger overrides the global Logger for the channel.
+func WithLogger(logger zerolog.Logger) Option {
+	return func(c *config) {
+		c.logger = &logger
+	}
+}
+
+// WithReporter overrides the global Reporter for the channel.
+func WithReporter(reporter report.Reporter) Option {
+	return func(c *config) {
+		c.reporter = reporter
+	}
+}
+
+// WithHistory overrides the global HistorySize for the channel. A size of
+// zero disables its history.
+func WithHistory(size int) Option {
+	return func(c *config) {
+		c.history = size
+	}
+}
+
+func newConfig(opts []Option) config {
+	c := config{timeout: defaultChannelTimeout, history: HistorySize}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	if c.logger == nil {
+		c.logger = &Logger
+	}
+
+	return c
+}
+
+// emit sends the event to the reporter of the channel.
+func (c config) emit(e report.Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	switch {
+	case c.reporter != nil:
+		c.reporter.Report(e)
+	case Reporter != nil:
+		Reporter.Report(e)
+	default:
+		report.NewLogReporter(*c.logger).Report(e)
+	}
+}
@@ -0,0 +1,44 @@
+package channel
+
+import (
+	"expvar"
+	"sync"
+
+	"go.dedis.ch/debugtools/registry"
+)
+
+var publishOnce sync.Once
+
+// PublishExpvar publishes the state of the package with expvar, and enables
+// the registry:
+//
+//   - debugtools.channel.blocked lists the channels goroutines are blocked on,
+//   - debugtools.channel.stats gives the statistics of the channels,
+//   - debugtools.channel.timeouts counts the timeouts per channel name.
+//
+// It can be called several times, and enables the registry again each time.
+func PublishExpvar() {
+	registry.Enable()
+
+	publishOnce.Do(func() {
+		expvar.Publish("debugtools.channel.blocked", expvar.Func(func() any {
+			return registry.ListFunc(func(key any) bool {
+				_, ok := key.(*monitor)
+				return ok
+			})
+		}))
+
+		expvar.Publish("debugtools.channel.stats", expvar.Func(func() any {
+			return Stats()
+		}))
+
+		expvar.Publish("debugtools.channel.timeouts", expvar.Func(func() any {
+			timeouts := make(map[string]uint64)
+			for _, s := range Stats() {
+				timeouts[s.Name] = s.Timeouts
+			}
+
+			return timeouts
+		}))
+	})
+}
@@ -6,6 +6,31 @@
 //
 //	CRY_LOG=trace
 //	CRY_LOG=info
+//
+// Timeouts are emitted as events of go.dedis.ch/debugtools/report, which are
+// logged unless a Reporter is set globally or with WithReporter.
+//
+// In CI, a goroutine failing to use a channel in time can panic instead, or
+// the process can exit with report.ExitCode after dumping all the goroutines:
+//
+//	CRY_ACTION=panic
+//	CRY_ACTION=exit
+//
+// All the goroutines can also be dumped to a file in a given directory on a
+// timeout, while the report only keeps the stacks of the goroutines blocked
+// on the channel:
+//
+//	CRY_DUMP=/tmp/goroutines
+//
+// A channel can also remember its last sends and receives, which are added to
+// its timeout reports, when HistorySize is set or with WithHistory.
+//
+// The goroutines blocked on the channels are added to the debugchan.blocked
+// pprof profile, and to runtime/trace regions such as "Timed blocks send"
+// when tracing.
+//
+// The channels on which goroutines are blocked are listed in the registry of
+// go.dedis.ch/debugtools/registry, when it is enabled.
 package channel
 
 import (
@@ -13,12 +38,20 @@ import (
 	"time"
 
 	"github.com/rs/zerolog"
+	"go.dedis.ch/debugtools/report"
 )
 
 // EnvLogLevel is the name of the environment variable to change the logging
 // level.
 const EnvLogLevel = "CRY_LOG"
 
+// EnvTimeoutAction is the name of the environment variable to set OnTimeout,
+// to either log, panic or exit.
+const EnvTimeoutAction = "CRY_ACTION"
+
+// EnvDumpDir is the name of the environment variable to set DumpDir.
+const EnvDumpDir = "CRY_DUMP"
+
 const defaultLogLevel = zerolog.WarnLevel
 
 func init() {
@@ -44,6 +77,14 @@ func init() {
 	}
 
 	Logger = Logger.Level(level)
+
+	action, err := report.ParseAction(os.Getenv(EnvTimeoutAction))
+	if err != nil {
+		Logger.Warn().Err(err).Msg("ignoring " + EnvTimeoutAction)
+	}
+
+	OnTimeout = action
+	DumpDir = os.Getenv(EnvDumpDir)
 }
 
 var logout = zerolog.ConsoleWriter{
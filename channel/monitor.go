@@ -0,0 +1,186 @@
+package channel
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.dedis.ch/debugtools/internal/goroutine"
+	"go.dedis.ch/debugtools/registry"
+	"go.dedis.ch/debugtools/report"
+	"go.dedis.ch/debugtools/timeline"
+)
+
+// Modes in which a goroutine is blocked on a channel.
+const (
+	modeSend    = "send"
+	modeReceive = "receive"
+)
+
+// lastChannelID is the last identifier given to a Timed channel.
+var lastChannelID uint64
+
+// monitor keeps track of the goroutines blocked on a Timed channel, so that
+// the channel is listed in the registry while they are.
+type monitor struct {
+	mutex   sync.Mutex
+	name    string
+	status  func() string
+	waiters []registry.Goroutine
+	// generation is the generation of the registry the channel was added to,
+	// or 0 if it is not in the registry.
+	generation uint64
+	// blocked is allocated apart from the monitor, so that the statistics can
+	// refer to it without keeping the monitor alive.
+	blocked *blockedCounts
+	// history, if any, keeps the last sends and receives.
+	history *report.History
+}
+
+func newMonitor(name string, status func() string, historySize int) *monitor {
+	return &monitor{
+		name:    name,
+		status:  status,
+		blocked: &blockedCounts{},
+		history: report.NewHistory(historySize),
+	}
+}
+
+// record adds the operation of the calling goroutine to the history of the
+// channel, if any.
+func (m *monitor) record(action string) {
+	if m.history == nil {
+		return
+	}
+
+	m.history.Add(report.Record{
+		Time:      time.Now(),
+		Goroutine: goroutine.ID(),
+		Action:    action,
+		Site:      goroutine.Caller("go.dedis.ch/debugtools/channel.(*"),
+	})
+}
+
+// wait counts the calling goroutine as blocked on the channel in the given
+// mode, adds it to the profile, to a trace region and to the timeline, and
+// records it if the registry or the dumps are enabled. The returned function
+// must be called once it is not blocked anymore.
+func (m *monitor) wait(mode string) func() {
+	blocked := &m.blocked.senders
+	if mode == modeReceive {
+		blocked = &m.blocked.receivers
+	}
+
+	atomic.AddInt64(blocked, 1)
+
+	// skip 1 frame, wait itself, so that the profile starts at the Send or
+	// Receive of the channel
+	key := atomic.AddUint64(&lastBlockedID, 1)
+	blockedProfile.Add(key, 1)
+
+	region := startRegion(m.name, mode)
+	since := time.Now()
+
+	unblocked := func() {
+		atomic.AddInt64(blocked, -1)
+		blockedProfile.Remove(key)
+		endRegion(region)
+
+		if timeline.IsOn() {
+			timeline.Record(timeline.Span{
+				Category:  "channel",
+				Name:      "Timed " + m.name + " " + mode,
+				Goroutine: goroutine.ID(),
+				Start:     since,
+				End:       time.Now(),
+			})
+		}
+	}
+
+	if !registry.IsOn() && DumpDir == "" {
+		return unblocked
+	}
+
+	w := registry.Goroutine{
+		ID:    goroutine.ID(),
+		Mode:  mode,
+		Since: since,
+		Stack: string(debug.Stack()),
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.waiters = append(m.waiters, w)
+	m.updateRegistry()
+
+	return func() {
+		unblocked()
+
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+
+		for i, other := range m.waiters {
+			if other.ID == w.ID && other.Since == w.Since {
+				m.waiters = append(m.waiters[:i], m.waiters[i+1:]...)
+				break
+			}
+		}
+
+		m.updateRegistry()
+	}
+}
+
+// goroutines returns the IDs of the goroutines blocked on the channel.
+func (m *monitor) goroutines() []uint64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	ids := make([]uint64, len(m.waiters))
+	for i, w := range m.waiters {
+		ids[i] = w.ID
+	}
+
+	return ids
+}
+
+// state returns the state of the channel for the registry.
+func (m *monitor) state() registry.State {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var senders, receivers int
+	for _, w := range m.waiters {
+		if w.Mode == modeSend {
+			senders++
+		} else {
+			receivers++
+		}
+	}
+
+	return registry.State{
+		Kind: "Timed",
+		Name: m.name,
+		Status: fmt.Sprintf("%s, %d blocked sender(s), %d blocked receiver(s)",
+			m.status(), senders, receivers),
+		Waiters: append([]registry.Goroutine(nil), m.waiters...),
+	}
+}
+
+// updateRegistry adds the channel to the registry while goroutines are
+// blocked on it, and adds it again if the registry forgot it since. It must be
+// called with the monitor locked.
+func (m *monitor) updateRegistry() {
+	generation := registry.Generation()
+
+	switch {
+	case len(m.waiters) > 0 && registry.IsOn() && m.generation != generation:
+		m.generation = generation
+		registry.Add(m, m.state)
+	case len(m.waiters) == 0 && m.generation != 0:
+		registry.Remove(m)
+		m.generation = 0
+	}
+}
@@ -0,0 +1,14 @@
+package channel
+
+import (
+	"runtime/pprof"
+)
+
+// blockedProfile is the profile of the goroutines blocked on the Timed
+// channels. It can be read with go tool pprof, e.g. from net/http/pprof at
+// /debug/pprof/debugchan.blocked. Each blocked goroutine is added with a new
+// identifier as the key.
+var blockedProfile = pprof.NewProfile("debugchan.blocked")
+
+// lastBlockedID is the last identifier given to a blocked goroutine.
+var lastBlockedID uint64
@@ -0,0 +1,118 @@
+package channel
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// ChannelStats are the statistics of the Timed channels of a name.
+type ChannelStats struct {
+	// Name is the name given to the channels with WithName. The channels
+	// without a name share the same statistics, with an empty name.
+	Name string
+	// Len and Cap are the total number of elements in, and capacity of, the
+	// channels still in use.
+	Len int
+	Cap int
+	// BlockedSenders and BlockedReceivers are the number of goroutines
+	// currently blocked on the channels.
+	BlockedSenders   int64
+	BlockedReceivers int64
+	// Timeouts is the number of goroutines which failed to use the channels
+	// in time so far.
+	Timeouts uint64
+}
+
+// blockedCounts are the number of goroutines blocked on a channel.
+type blockedCounts struct {
+	senders   int64
+	receivers int64
+}
+
+// liveChannel is a channel still in use.
+type liveChannel struct {
+	name    string
+	blocked *blockedCounts
+	size    func() (int, int)
+}
+
+// channelStatistics keeps track of the channels in use, which are forgotten
+// once their monitor is garbage collected, and of the timeouts per name.
+type channelStatistics struct {
+	mutex    sync.Mutex
+	live     map[uintptr]liveChannel
+	timeouts map[string]uint64
+}
+
+var statistics = channelStatistics{
+	live:     make(map[uintptr]liveChannel),
+	timeouts: make(map[string]uint64),
+}
+
+// Stats returns a snapshot of the statistics of the Timed channels, sorted by
+// name.
+func Stats() []ChannelStats {
+	statistics.mutex.Lock()
+	defer statistics.mutex.Unlock()
+
+	byName := make(map[string]*ChannelStats)
+	get := func(name string) *ChannelStats {
+		s := byName[name]
+		if s == nil {
+			s = &ChannelStats{Name: name}
+			byName[name] = s
+		}
+
+		return s
+	}
+
+	for _, c := range statistics.live {
+		s := get(c.name)
+
+		length, capacity := c.size()
+		s.Len += length
+		s.Cap += capacity
+		s.BlockedSenders += atomic.LoadInt64(&c.blocked.senders)
+		s.BlockedReceivers += atomic.LoadInt64(&c.blocked.receivers)
+	}
+
+	for name, n := range statistics.timeouts {
+		get(name).Timeouts = n
+	}
+
+	stats := make([]ChannelStats, 0, len(byName))
+	for _, s := range byName {
+		stats = append(stats, *s)
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+
+	return stats
+}
+
+// add keeps track of the channel of the monitor m, until m is garbage
+// collected. The statistics only refer to m by its address so that it can
+// be.
+func (s *channelStatistics) add(m *monitor, name string, size func() (int, int)) {
+	key := uintptr(unsafe.Pointer(m))
+
+	s.mutex.Lock()
+	s.live[key] = liveChannel{name: name, blocked: m.blocked, size: size}
+	s.mutex.Unlock()
+
+	runtime.SetFinalizer(m, func(*monitor) {
+		s.mutex.Lock()
+		delete(s.live, key)
+		s.mutex.Unlock()
+	})
+}
+
+// timedOut counts a timeout of a channel of the given name.
+func (s *channelStatistics) timedOut(name string) {
+	s.mutex.Lock()
+	s.timeouts[name]++
+	s.mutex.Unlock()
+}
@@ -0,0 +1,24 @@
+package channel
+
+import (
+	"context"
+	"runtime/trace"
+)
+
+// startRegion starts a runtime/trace region of a goroutine blocked on the
+// channel name in the given mode, e.g. "Timed blocks send", if tracing is
+// enabled. The returned region, which may be nil, must be ended by the same
+// goroutine with endRegion.
+func startRegion(name, mode string) *trace.Region {
+	if !trace.IsEnabled() {
+		return nil
+	}
+
+	return trace.StartRegion(context.Background(), "Timed "+name+" "+mode)
+}
+
+func endRegion(r *trace.Region) {
+	if r != nil {
+		r.End()
+	}
+}
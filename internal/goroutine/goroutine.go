@@ -0,0 +1,56 @@
+// Package goroutine gives access to goroutine information the runtime does
+// not expose, for the bookkeeping shared by the debug packages.
+package goroutine
+
+import (
+	"bytes"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+var prefix = []byte("goroutine ")
+
+// ID returns the identifier of the calling goroutine.
+// The runtime does not expose it, so it is parsed from the header of the
+// goroutine's stack trace, which looks like "goroutine 18 [running]:".
+func ID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+
+	header := bytes.TrimPrefix(buf[:n], prefix)
+	end := bytes.IndexByte(header, ' ')
+	if end < 0 {
+		return 0
+	}
+
+	id, err := strconv.ParseUint(string(header[:end]), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
+
+// Caller returns the first function of the calling goroutine's stack which
+// neither belongs to the runtime nor starts with skip, with its location,
+// e.g. "main.(*Peers).Add peers.go:42". It is cheaper than parsing a stack
+// given by runtime/debug.Stack.
+func Caller(skip string) string {
+	var pcs [32]uintptr
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") &&
+			!strings.HasPrefix(frame.Function, skip) {
+			return frame.Function + " " + filepath.Base(frame.File) + ":" + strconv.Itoa(frame.Line)
+		}
+
+		if !more {
+			return "unknown"
+		}
+	}
+}
@@ -0,0 +1,20 @@
+// Package testutil gathers the helpers shared by the tests of the debug
+// packages.
+package testutil
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+// lastName numbers the names given by UniqueName.
+var lastName uint64
+
+// UniqueName returns a name, made of the name of the test, which has never
+// been given before. The primitives of a name keep their statistics from one
+// run of a test to the next, so that a test checking them needs a new name
+// on each run.
+func UniqueName(t testing.TB) string {
+	return fmt.Sprintf("%s-%d", t.Name(), atomic.AddUint64(&lastName, 1))
+}
@@ -0,0 +1,165 @@
+// Package metrics exports the statistics of the sync and channel packages in
+// the OpenMetrics text format, so that they can be scraped by Prometheus
+// without depending on its client library:
+//
+//	http.Handle("/metrics/debugtools", metrics.Handler())
+//
+// The wait and hold times of the locks are only recorded while the debugging
+// of the sync package is on. The primitives are labelled by the name given
+// with WithName, the unnamed ones sharing an empty name.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.dedis.ch/debugtools/channel"
+	"go.dedis.ch/debugtools/sync"
+)
+
+// ContentType is the content type of the OpenMetrics text format.
+const ContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// Handler returns an http.Handler serving the metrics.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		WriteText(w)
+	})
+}
+
+// WriteText writes the current metrics in the OpenMetrics text format.
+func WriteText(w io.Writer) {
+	locks := sync.Stats()
+
+	family(w, "debugsync_lock_wait_seconds", "histogram",
+		"Time waited to acquire the locks.")
+	for _, l := range locks {
+		histogram(w, "debugsync_lock_wait_seconds", l.Wait, "kind", l.Kind, "name", l.Name)
+	}
+
+	family(w, "debugsync_lock_hold_seconds", "histogram",
+		"Time the locks have been held.")
+	for _, l := range locks {
+		histogram(w, "debugsync_lock_hold_seconds", l.Hold, "kind", l.Kind, "name", l.Name)
+	}
+
+	family(w, "debugsync_events", "counter",
+		"Events reported by the primitives, such as timeouts.")
+	for _, e := range sync.EventCounts() {
+		sample(w, "debugsync_events_total", e.Count,
+			"primitive", e.Primitive, "name", e.Name, "kind", string(e.Kind))
+	}
+
+	family(w, "debugsync_waitgroup_counter", "gauge",
+		"Total counter of the wait groups.")
+	for _, c := range sync.WaitGroupCounters() {
+		sample(w, "debugsync_waitgroup_counter", c.Counter, "name", c.Name)
+	}
+
+	pools := sync.Pools()
+
+	counters := []struct {
+		name, help string
+		value      func(sync.PoolStats) uint64
+	}{
+		{"debugsync_pool_hits", "Objects reused by the pools.",
+			func(s sync.PoolStats) uint64 { return s.Hits }},
+		{"debugsync_pool_misses", "Objects requested from the empty pools.",
+			func(s sync.PoolStats) uint64 { return s.Misses }},
+		{"debugsync_pool_allocations", "Objects created by the pools.",
+			func(s sync.PoolStats) uint64 { return s.Allocations }},
+	}
+
+	for _, c := range counters {
+		family(w, c.name, "counter", c.help)
+		for _, s := range pools {
+			sample(w, c.name+"_total", c.value(s), "name", s.Name)
+		}
+	}
+
+	family(w, "debugsync_pool_outstanding", "gauge",
+		"Objects given by the pools and not put back yet.")
+	for _, s := range pools {
+		sample(w, "debugsync_pool_outstanding", s.Outstanding, "name", s.Name)
+	}
+
+	channels := channel.Stats()
+
+	gauges := []struct {
+		name, help string
+		value      func(channel.ChannelStats) int64
+	}{
+		{"debugchan_length", "Elements in the channels.",
+			func(s channel.ChannelStats) int64 { return int64(s.Len) }},
+		{"debugchan_capacity", "Capacity of the channels.",
+			func(s channel.ChannelStats) int64 { return int64(s.Cap) }},
+		{"debugchan_blocked_senders", "Goroutines blocked sending on the channels.",
+			func(s channel.ChannelStats) int64 { return s.BlockedSenders }},
+		{"debugchan_blocked_receivers", "Goroutines blocked receiving from the channels.",
+			func(s channel.ChannelStats) int64 { return s.BlockedReceivers }},
+	}
+
+	for _, g := range gauges {
+		family(w, g.name, "gauge", g.help)
+		for _, s := range channels {
+			sample(w, g.name, g.value(s), "name", s.Name)
+		}
+	}
+
+	family(w, "debugchan_timeouts", "counter",
+		"Goroutines which failed to use the channels in time.")
+	for _, s := range channels {
+		sample(w, "debugchan_timeouts_total", s.Timeouts, "name", s.Name)
+	}
+
+	fmt.Fprintln(w, "# EOF")
+}
+
+func family(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+
+	if strings.HasSuffix(name, "_seconds") {
+		fmt.Fprintf(w, "# UNIT %s seconds\n", name)
+	}
+
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+}
+
+func histogram(w io.Writer, name string, h sync.Histogram, labels ...string) {
+	var cumulated uint64
+
+	for i, bound := range h.Bounds {
+		cumulated += h.Counts[i]
+		sample(w, name+"_bucket", cumulated, append(labels, "le", seconds(bound))...)
+	}
+
+	sample(w, name+"_bucket", h.Count, append(labels, "le", "+Inf")...)
+	sample(w, name+"_sum", seconds(h.Sum), labels...)
+	sample(w, name+"_count", h.Count, labels...)
+}
+
+// sample writes a sample of the given value, with the labels given as pairs
+// of name and value.
+func sample(w io.Writer, name string, value any, labels ...string) {
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", labels[i], escape(labels[i+1])))
+	}
+
+	fmt.Fprintf(w, "%s{%s} %v\n", name, strings.Join(pairs, ","), value)
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'g', -1, 64)
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(value string) string {
+	return escaper.Replace(value)
+}
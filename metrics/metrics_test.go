@@ -0,0 +1,54 @@
+package metrics_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/debugtools/channel"
+	"go.dedis.ch/debugtools/internal/testutil"
+	"go.dedis.ch/debugtools/metrics"
+	"go.dedis.ch/debugtools/sync"
+)
+
+func TestHandler(t *testing.T) {
+	sync.DebugIsOn = true
+	defer func() { sync.DebugIsOn = false }()
+	sync.ResetStats()
+
+	buffers, blocks := testutil.UniqueName(t), testutil.UniqueName(t)
+
+	m := sync.NewMutex(sync.WithName(`peers "table"`))
+	m.Lock()
+	time.Sleep(time.Millisecond)
+	m.Unlock()
+
+	wg := sync.NewWaitGroup(sync.WithName("workers"))
+	wg.Add(2)
+	defer wg.Add(-2)
+
+	p := sync.NewTypedPool(func() *[]byte { return new([]byte) }, sync.WithName(buffers))
+	_ = p.Get()
+
+	c := channel.WithExpiration[int](4, channel.WithName(blocks))
+	c.Send(0)
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	require.Equal(t, metrics.ContentType, rec.Header().Get("Content-Type"))
+
+	out := rec.Body.String()
+	require.True(t, strings.HasSuffix(out, "# EOF\n"))
+	require.Contains(t, out, "# TYPE debugsync_lock_hold_seconds histogram\n")
+	require.Contains(t, out, `debugsync_lock_hold_seconds_bucket{kind="Mutex",name="peers \"table\"",le="+Inf"} 1`)
+	require.Contains(t, out, `debugsync_lock_hold_seconds_count{kind="Mutex",name="peers \"table\""} 1`)
+	require.Contains(t, out, `debugsync_waitgroup_counter{name="workers"} 2`)
+	require.Contains(t, out, `debugsync_pool_allocations_total{name="`+buffers+`"} 1`)
+	require.Contains(t, out, `debugsync_pool_outstanding{name="`+buffers+`"} 1`)
+	require.Contains(t, out, `debugchan_length{name="`+blocks+`"} 1`)
+	require.Contains(t, out, `debugchan_capacity{name="`+blocks+`"} 4`)
+	require.Contains(t, out, `debugchan_timeouts_total{name="`+blocks+`"} 0`)
+}
@@ -0,0 +1,68 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Handler returns an http.Handler listing the state of the recorded
+// primitives. The output is plain text by default and JSON when the request
+// has the "format=json" query parameter.
+func Handler() http.Handler {
+	return http.HandlerFunc(serve)
+}
+
+func serve(w http.ResponseWriter, r *http.Request) {
+	states := List()
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+
+		err := json.NewEncoder(w).Encode(states)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if !IsOn() {
+		fmt.Fprintln(w, "registry is disabled")
+		return
+	}
+
+	WriteText(w, states)
+}
+
+// WriteText writes a human-readable description of the given states.
+func WriteText(w io.Writer, states []State) {
+	fmt.Fprintf(w, "%d primitive(s) in use\n", len(states))
+
+	for _, s := range states {
+		fmt.Fprintf(w, "\n%s [%s] %s\n", s.Name, s.Kind, s.Status)
+
+		for _, g := range s.Holders {
+			writeGoroutine(w, "held by", g)
+		}
+
+		for _, g := range s.Waiters {
+			writeGoroutine(w, "waited on by", g)
+		}
+	}
+}
+
+func writeGoroutine(w io.Writer, role string, g Goroutine) {
+	fmt.Fprintf(w, "\t%s goroutine %d (%s) for %v:\n", role, g.ID, g.Mode,
+		time.Since(g.Since).Round(time.Millisecond))
+
+	stack := strings.TrimRight(g.Stack, "\n")
+	for _, line := range strings.Split(stack, "\n") {
+		fmt.Fprintf(w, "\t\t%s\n", line)
+	}
+}
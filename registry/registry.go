@@ -0,0 +1,137 @@
+// Package registry keeps track of the instrumented primitives of the sync and
+// channel packages, so that a running process can tell which of them are
+// currently held or blocked, and by whom.
+//
+// The registry is disabled by default. Once enabled, a primitive is listed
+// while at least one goroutine holds it or is blocked on it. A primitive
+// already in use when the registry is enabled is listed from its next lock,
+// unlock or wait. Its state can be
+// served next to net/http/pprof with:
+//
+//	registry.Enable()
+//	http.Handle("/debug/debugtools", registry.Handler())
+package registry
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Goroutine describes a goroutine holding or waiting on a primitive.
+type Goroutine struct {
+	ID    uint64    `json:"id"`
+	Mode  string    `json:"mode"`
+	Since time.Time `json:"since"`
+	Stack string    `json:"stack"`
+}
+
+// State is the state of an instrumented primitive at a given time.
+type State struct {
+	Kind    string      `json:"kind"`
+	Name    string      `json:"name"`
+	Status  string      `json:"status"`
+	Holders []Goroutine `json:"holders,omitempty"`
+	Waiters []Goroutine `json:"waiters,omitempty"`
+}
+
+// StateFunc returns the current state of a primitive.
+type StateFunc func() State
+
+var enabled atomic.Bool
+
+// generation counts the times the registry forgot its primitives.
+var generation atomic.Uint64
+
+var registry = struct {
+	sync.Mutex
+	primitives map[any]StateFunc
+}{
+	primitives: make(map[any]StateFunc),
+}
+
+// Enable starts recording the primitives in use.
+func Enable() {
+	enabled.Store(true)
+}
+
+// Disable stops recording the primitives and forgets the ones already
+// recorded.
+func Disable() {
+	enabled.Store(false)
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	registry.primitives = make(map[any]StateFunc)
+	generation.Add(1)
+}
+
+// Generation changes each time the registry forgets its primitives. A
+// primitive added in an earlier generation must be added again to be listed.
+// It is never 0.
+func Generation() uint64 {
+	return generation.Load() + 1
+}
+
+// IsOn tells whether the registry is enabled.
+func IsOn() bool {
+	return enabled.Load()
+}
+
+// Add records the primitive identified by key, whose state is given by state.
+// It does nothing when the registry is disabled.
+func Add(key any, state StateFunc) {
+	if !IsOn() {
+		return
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	registry.primitives[key] = state
+}
+
+// Remove forgets the primitive identified by key.
+func Remove(key any) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	delete(registry.primitives, key)
+}
+
+// List returns the state of the recorded primitives, sorted by kind and name.
+func List() []State {
+	return ListFunc(func(any) bool { return true })
+}
+
+// ListFunc returns the state of the recorded primitives whose key satisfies
+// keep, sorted by kind and name. It lets a package list its own primitives.
+func ListFunc(keep func(key any) bool) []State {
+	registry.Lock()
+	funcs := make([]StateFunc, 0, len(registry.primitives))
+	for key, f := range registry.primitives {
+		if keep(key) {
+			funcs = append(funcs, f)
+		}
+	}
+	registry.Unlock()
+
+	// The state functions are called without holding the registry lock, as
+	// they usually need to take the lock of the primitive, which may itself
+	// be held while adding or removing the primitive.
+	states := make([]State, len(funcs))
+	for i, f := range funcs {
+		states[i] = f()
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Kind != states[j].Kind {
+			return states[i].Kind < states[j].Kind
+		}
+		return states[i].Name < states[j].Name
+	})
+
+	return states
+}
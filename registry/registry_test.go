@@ -0,0 +1,168 @@
+package registry_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/debugtools/channel"
+	"go.dedis.ch/debugtools/registry"
+	"go.dedis.ch/debugtools/sync"
+)
+
+func TestRegistryDisabled(t *testing.T) {
+	registry.Disable()
+
+	registry.Add(t, func() registry.State { return registry.State{} })
+	require.Empty(t, registry.List())
+
+	rec := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	require.Contains(t, rec.Body.String(), "registry is disabled")
+}
+
+func TestRegistryList(t *testing.T) {
+	registry.Enable()
+	defer registry.Disable()
+
+	registry.Add("b", func() registry.State { return registry.State{Kind: "Mutex", Name: "b"} })
+	registry.Add("a", func() registry.State { return registry.State{Kind: "Mutex", Name: "a"} })
+	registry.Add("c", func() registry.State { return registry.State{Kind: "Cond", Name: "c"} })
+
+	names := []string{}
+	for _, s := range registry.List() {
+		names = append(names, s.Name)
+	}
+	require.Equal(t, []string{"c", "a", "b"}, names)
+
+	registry.Remove("a")
+	require.Len(t, registry.List(), 2)
+}
+
+func TestRegistryListFunc(t *testing.T) {
+	registry.Enable()
+	defer registry.Disable()
+
+	registry.Add("a", func() registry.State { return registry.State{Kind: "Mutex", Name: "a"} })
+	registry.Add(1, func() registry.State { return registry.State{Kind: "Timed", Name: "b"} })
+
+	states := registry.ListFunc(func(key any) bool {
+		_, ok := key.(string)
+		return ok
+	})
+	require.Len(t, states, 1)
+	require.Equal(t, "a", states[0].Name)
+}
+
+func TestRegistryMutex(t *testing.T) {
+	sync.DebugIsOn = true
+	defer func() { sync.DebugIsOn = false }()
+
+	registry.Enable()
+	defer registry.Disable()
+
+	var m sync.Mutex
+
+	m.Lock()
+	waiting := make(chan struct{})
+	go func() {
+		close(waiting)
+		m.Lock()
+		m.Unlock() //nolint:staticcheck // SA2001: empty critical section IGNORED !
+	}()
+	<-waiting
+
+	require.Eventually(t, func() bool {
+		states := registry.List()
+		return len(states) == 1 && len(states[0].Waiters) == 1
+	}, time.Second, time.Millisecond)
+
+	states := registry.List()
+	require.Equal(t, "Mutex", states[0].Kind)
+	require.Len(t, states[0].Holders, 1)
+	require.Contains(t, states[0].Holders[0].Stack, "TestRegistryMutex")
+	require.Equal(t, "locked, 1 waiter(s)", states[0].Status)
+
+	m.Unlock()
+
+	require.Eventually(t, func() bool {
+		return len(registry.List()) == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestRegistryDisableEnable(t *testing.T) {
+	sync.DebugIsOn = true
+	defer func() { sync.DebugIsOn = false }()
+
+	registry.Enable()
+	defer registry.Disable()
+
+	var m sync.Mutex
+
+	m.Lock()
+	require.Len(t, registry.List(), 1)
+
+	registry.Disable()
+	require.Empty(t, registry.List())
+	registry.Enable()
+
+	// the held lock is listed again from its next transition
+	waiting := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		close(waiting)
+		m.Lock()
+		m.Unlock() //nolint:staticcheck // SA2001: empty critical section IGNORED !
+		close(done)
+	}()
+	<-waiting
+
+	require.Eventually(t, func() bool {
+		states := registry.List()
+		return len(states) == 1 && len(states[0].Holders) == 1 && len(states[0].Waiters) == 1
+	}, time.Second, time.Millisecond)
+
+	m.Unlock()
+	<-done
+
+	require.Empty(t, registry.List())
+}
+
+func TestRegistryHandler(t *testing.T) {
+	registry.Enable()
+	defer registry.Disable()
+
+	c := channel.WithExpiration[int](0)
+	go c.ReceiveWithTimeout(time.Minute)
+
+	require.Eventually(t, func() bool {
+		return len(registry.List()) == 1
+	}, time.Second, time.Millisecond)
+
+	srv := httptest.NewServer(registry.Handler())
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL + "?format=json")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var states []registry.State
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&states))
+	require.Len(t, states, 1)
+	require.Equal(t, "Timed", states[0].Kind)
+	require.Equal(t, "0/0 elements, 0 blocked sender(s), 1 blocked receiver(s)", states[0].Status)
+	require.Len(t, states[0].Waiters, 1)
+	require.Equal(t, "receive", states[0].Waiters[0].Mode)
+
+	rec := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	out := rec.Body.String()
+	require.True(t, strings.HasPrefix(out, "1 primitive(s) in use"))
+	require.Contains(t, out, "waited on by goroutine")
+
+	c.Send(1)
+}
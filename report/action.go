@@ -0,0 +1,46 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Action is what a package does once a goroutine waiting on one of its
+// primitives timed out and the event has been reported.
+type Action string
+
+const (
+	// ActionLog only reports the event, and the goroutine keeps waiting.
+	ActionLog = Action("log")
+	// ActionPanic panics in the waiting goroutine with the report of the
+	// event.
+	ActionPanic = Action("panic")
+	// ActionExit dumps the stacks of all the goroutines on the standard error
+	// and exits the process with ExitCode.
+	ActionExit = Action("exit")
+)
+
+// ExitCode is the exit code of the process when ActionExit is triggered, so
+// that a deadlock can be told apart from a failure in CI.
+const ExitCode = 86
+
+// ParseAction parses the name of an action, case-insensitively. An empty
+// name is ActionLog.
+func ParseAction(name string) (Action, error) {
+	switch a := Action(strings.ToLower(name)); a {
+	case "", ActionLog:
+		return ActionLog, nil
+	case ActionPanic, ActionExit:
+		return a, nil
+	default:
+		return ActionLog, fmt.Errorf("unknown timeout action %q", name)
+	}
+}
+
+// Exit writes the report of the event followed by the stacks of all the
+// goroutines on the standard error, and exits with ExitCode.
+func Exit(e Event) {
+	fmt.Fprintf(os.Stderr, "%s\n\n%s\n", e, AllStacks())
+	os.Exit(ExitCode)
+}
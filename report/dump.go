@@ -0,0 +1,92 @@
+package report
+
+import (
+	"bytes"
+	"os"
+	"runtime"
+	"strconv"
+)
+
+// AllStacks returns the stacks of all the goroutines, as formatted by
+// runtime.Stack.
+func AllStacks() []byte {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// FilterStacks returns the stacks of the goroutines ids found in dump, a dump
+// of goroutines given by AllStacks.
+func FilterStacks(dump []byte, ids []uint64) string {
+	wanted := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	var filtered [][]byte
+
+	for _, stack := range bytes.Split(dump, []byte("\n\n")) {
+		if wanted[stackID(stack)] {
+			filtered = append(filtered, bytes.TrimSpace(stack))
+		}
+	}
+
+	return string(bytes.Join(filtered, []byte("\n\n")))
+}
+
+// stackID returns the ID of the goroutine of the stack, which starts with
+// "goroutine <id> [", or zero if there is none.
+func stackID(stack []byte) uint64 {
+	stack = bytes.TrimSpace(stack)
+
+	rest, found := bytes.CutPrefix(stack, []byte("goroutine "))
+	if !found {
+		return 0
+	}
+
+	end := bytes.IndexByte(rest, ' ')
+	if end < 0 {
+		return 0
+	}
+
+	id, err := strconv.ParseUint(string(rest[:end]), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
+
+// WithDump dumps all the goroutines to a new file in dir, and returns e with
+// the path of the file and the stacks of the goroutines ids. The filtered
+// stacks are kept even if the file cannot be written.
+func WithDump(e Event, dir string, ids []uint64) (Event, error) {
+	dump := AllStacks()
+	e.Goroutines = FilterStacks(dump, ids)
+
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return e, err
+	}
+
+	f, err := os.CreateTemp(dir, "goroutines-*.txt")
+	if err != nil {
+		return e, err
+	}
+
+	_, err = f.Write(dump)
+	if err != nil {
+		_ = f.Close()
+		return e, err
+	}
+
+	e.DumpFile = f.Name()
+
+	return e, f.Close()
+}
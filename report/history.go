@@ -0,0 +1,83 @@
+package report
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Record is an operation on a primitive, kept in its History.
+type Record struct {
+	Time      time.Time `json:"time"`
+	Goroutine uint64    `json:"goroutine"`
+	// Action is the operation, e.g. "lock", "unlock" or "send".
+	Action string `json:"action"`
+	// Site is the function performing the operation and its location, e.g.
+	// "main.(*Peers).Add peers.go:42", if known.
+	Site string `json:"site,omitempty"`
+}
+
+// String describes the record, e.g.
+// "15:04:05.000000 goroutine 12 lock at main.(*Peers).Add peers.go:42".
+func (r Record) String() string {
+	s := fmt.Sprintf("%s goroutine %d %s", r.Time.Format("15:04:05.000000"), r.Goroutine, r.Action)
+	if r.Site != "" {
+		s += " at " + r.Site
+	}
+
+	return s
+}
+
+// History is a ring buffer of the last records of a primitive, so that an
+// event can tell what happened to the primitive before. A nil History
+// records nothing.
+type History struct {
+	mutex   sync.Mutex
+	records []Record
+	// next is the index of the next record to overwrite, once the buffer is
+	// full.
+	next int
+}
+
+// NewHistory returns a History keeping the last size records, or nil if size
+// is not positive.
+func NewHistory(size int) *History {
+	if size <= 0 {
+		return nil
+	}
+
+	return &History{records: make([]Record, 0, size)}
+}
+
+// Add records r, forgetting the oldest record if the buffer is full.
+func (h *History) Add(r Record) {
+	if h == nil {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(h.records) < cap(h.records) {
+		h.records = append(h.records, r)
+		return
+	}
+
+	h.records[h.next] = r
+	h.next = (h.next + 1) % len(h.records)
+}
+
+// Records returns the records kept, oldest first.
+func (h *History) Records() []Record {
+	if h == nil {
+		return nil
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	records := make([]Record, 0, len(h.records))
+	records = append(records, h.records[h.next:]...)
+
+	return append(records, h.records[:h.next]...)
+}
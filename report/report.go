@@ -0,0 +1,189 @@
+// Package report defines the structured events emitted by the sync and
+// channel packages when a primitive times out or is misused, and the
+// reporters receiving them.
+//
+// By default, the events are logged with the logger of the primitive. A
+// Reporter can be set instead, globally or per primitive, to collect them in
+// memory, write them as JSON, or fan them out to several sinks:
+//
+//	sync.Reporter = report.Multi(report.NewLogReporter(sync.Logger), jsonReporter)
+package report
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind is the kind of an event.
+type Kind string
+
+const (
+	// KindTimeout is emitted when a goroutine waits on a primitive for longer
+	// than its timeout.
+	KindTimeout = Kind("timeout")
+	// KindHoldTimeout is emitted when a primitive is held for longer than its
+	// timeout.
+	KindHoldTimeout = Kind("hold-timeout")
+	// KindLockOrder is emitted when a cycle is found in the lock-order graph.
+	KindLockOrder = Kind("lock-order")
+	// KindLockRank is emitted when a lock is acquired while holding a lock
+	// of a higher rank.
+	KindLockRank = Kind("lock-rank")
+	// KindSelfDeadlock is emitted when a goroutine blocks on a lock it
+	// already holds.
+	KindSelfDeadlock = Kind("self-deadlock")
+	// KindAssertion is emitted when an assertion about a primitive fails,
+	// such as a lock which must be held.
+	KindAssertion = Kind("assertion")
+	// KindMisuse is emitted when a primitive is used in a way which may
+	// deadlock.
+	KindMisuse = Kind("misuse")
+	// KindPanic is emitted when a function run by a primitive, such as the
+	// one of Once.Do, panics.
+	KindPanic = Kind("panic")
+)
+
+// Severity tells how serious an event is.
+type Severity string
+
+const (
+	// SeverityWarning is for events which may lead to a problem.
+	SeverityWarning = Severity("warning")
+	// SeverityError is for events which are a problem.
+	SeverityError = Severity("error")
+)
+
+// Goroutine describes a goroutine related to an event.
+type Goroutine struct {
+	ID uint64 `json:"id"`
+	// Role tells how the goroutine relates to the event, e.g. "lock held by".
+	Role string `json:"role"`
+	// Duration is for how long the goroutine has been in that role, if known.
+	Duration time.Duration `json:"duration,omitempty"`
+	Stack    string        `json:"stack"`
+}
+
+// String describes the goroutine, e.g. "lock held by goroutine 12 for 2s".
+func (g Goroutine) String() string {
+	if g.Duration == 0 {
+		return fmt.Sprintf("%s goroutine %d, at:\n%s", g.Role, g.ID, g.Stack)
+	}
+
+	return fmt.Sprintf("%s goroutine %d for %v, at:\n%s", g.Role, g.ID, g.Duration, g.Stack)
+}
+
+// Event is a structured report about a primitive.
+type Event struct {
+	Kind     Kind      `json:"kind"`
+	Severity Severity  `json:"severity"`
+	Time     time.Time `json:"time"`
+	// Primitive is the type of the primitive, e.g. "Mutex".
+	Primitive string `json:"primitive"`
+	Name      string `json:"name"`
+	Message   string `json:"message"`
+	// Goroutine is the goroutine which triggered the event, from Stack.
+	Goroutine uint64 `json:"goroutine,omitempty"`
+	Stack     string `json:"stack,omitempty"`
+	// Duration is for how long the goroutine has been waiting or holding
+	// the primitive, if relevant.
+	Duration time.Duration `json:"duration,omitempty"`
+	// Related are the other goroutines involved, such as the holders of a
+	// lock a goroutine is waiting for.
+	Related []Goroutine `json:"related,omitempty"`
+	// Goroutines are the stacks of the goroutines involved, filtered from a
+	// dump of all the goroutines when enabled.
+	Goroutines string `json:"goroutines,omitempty"`
+	// DumpFile is the file where all the goroutines have been dumped.
+	DumpFile string `json:"dump_file,omitempty"`
+	// History are the last operations on the primitive before the event,
+	// oldest first, if it keeps a History.
+	History []Record `json:"history,omitempty"`
+}
+
+// String formats the event as a human-readable report.
+func (e Event) String() string {
+	var b strings.Builder
+
+	b.WriteString(e.Message)
+
+	if e.Stack != "" {
+		fmt.Fprintf(&b, " : %s", e.Stack)
+	}
+
+	for _, g := range e.Related {
+		fmt.Fprintf(&b, "\n%v", g)
+	}
+
+	if e.Goroutines != "" {
+		fmt.Fprintf(&b, "\n\ngoroutines involved:\n%s", e.Goroutines)
+	}
+
+	if e.DumpFile != "" {
+		fmt.Fprintf(&b, "\nall goroutines dumped to %s", e.DumpFile)
+	}
+
+	if len(e.History) > 0 {
+		b.WriteString("\n\nhistory:")
+		for _, r := range e.History {
+			fmt.Fprintf(&b, "\n%v", r)
+		}
+	}
+
+	return b.String()
+}
+
+// Reporter receives the events emitted by the primitives. Implementations
+// must be safe for concurrent use.
+type Reporter interface {
+	Report(e Event)
+}
+
+// Func adapts a function to a Reporter.
+type Func func(e Event)
+
+// Report calls f(e).
+func (f Func) Report(e Event) {
+	f(e)
+}
+
+// Multi returns a Reporter forwarding the events to all the given reporters.
+func Multi(reporters ...Reporter) Reporter {
+	return Func(func(e Event) {
+		for _, r := range reporters {
+			r.Report(e)
+		}
+	})
+}
+
+// Collector is a Reporter keeping the events in memory, typically for tests.
+// The zero value is ready to use.
+type Collector struct {
+	mutex  sync.Mutex
+	events []Event
+}
+
+// Report stores the event.
+func (c *Collector) Report(e Event) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.events = append(c.events, e)
+}
+
+// Events returns the events collected so far.
+func (c *Collector) Events() []Event {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return append([]Event(nil), c.events...)
+}
+
+// Reset forgets the events collected so far.
+func (c *Collector) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.events = nil
+}
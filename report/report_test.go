@@ -0,0 +1,210 @@
+package report_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/debugtools/report"
+	"go.dedis.ch/debugtools/sync"
+)
+
+func TestEventString(t *testing.T) {
+	e := report.Event{
+		Message: "Mutex timed out when acquiring lock",
+		Stack:   "stack of 1",
+		Related: []report.Goroutine{
+			{ID: 2, Role: "lock held by", Duration: time.Second, Stack: "stack of 2"},
+		},
+	}
+
+	require.Equal(t, "Mutex timed out when acquiring lock : stack of 1\n"+
+		"lock held by goroutine 2 for 1s, at:\nstack of 2", e.String())
+}
+
+func TestCollectorAndMulti(t *testing.T) {
+	var a, b report.Collector
+	r := report.Multi(&a, &b)
+
+	r.Report(report.Event{Kind: report.KindTimeout})
+	r.Report(report.Event{Kind: report.KindMisuse})
+
+	require.Len(t, a.Events(), 2)
+	require.Equal(t, a.Events(), b.Events())
+
+	a.Reset()
+	require.Empty(t, a.Events())
+	require.Len(t, b.Events(), 2)
+}
+
+func TestLogReporter(t *testing.T) {
+	b := new(bytes.Buffer)
+	r := report.NewLogReporter(zerolog.New(b))
+
+	r.Report(report.Event{Kind: report.KindMisuse, Severity: report.SeverityWarning,
+		Name: "peers", Message: "recursive RLock"})
+
+	out := b.String()
+	require.Contains(t, out, `"level":"warn"`)
+	require.Contains(t, out, `"kind":"misuse"`)
+	require.Contains(t, out, `"name":"peers"`)
+	require.Contains(t, out, "recursive RLock")
+}
+
+func TestJSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+
+	r, err := report.OpenJSONFile(path)
+	require.NoError(t, err)
+
+	r.Report(report.Event{Kind: report.KindTimeout, Name: "a"})
+	r.Report(report.Event{Kind: report.KindLockOrder, Name: "b"})
+	require.NoError(t, r.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+
+	var e report.Event
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &e))
+	require.Equal(t, report.KindLockOrder, e.Kind)
+	require.Equal(t, "b", e.Name)
+}
+
+func TestSyncReporter(t *testing.T) {
+	sync.DebugIsOn = true
+	defer func() { sync.DebugIsOn = false }()
+
+	var collector report.Collector
+	m := sync.NewMutex(sync.WithName("peers"), sync.WithTimeout(10*time.Millisecond),
+		sync.WithReporter(&collector))
+
+	m.Lock()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		m.Unlock()
+	}()
+	m.Lock()
+	m.Unlock()
+
+	var timeout report.Event
+	for _, e := range collector.Events() {
+		if e.Kind == report.KindTimeout {
+			timeout = e
+		}
+	}
+
+	require.Equal(t, "Mutex", timeout.Primitive)
+	require.Equal(t, "peers", timeout.Name)
+	require.NotZero(t, timeout.Duration)
+	require.Len(t, timeout.Related, 1)
+	require.Equal(t, "lock held by", timeout.Related[0].Role)
+	require.Contains(t, timeout.Related[0].Stack, "TestSyncReporter")
+}
+
+func TestParseAction(t *testing.T) {
+	for name, expected := range map[string]report.Action{
+		"":      report.ActionLog,
+		"log":   report.ActionLog,
+		"PANIC": report.ActionPanic,
+		"exit":  report.ActionExit,
+	} {
+		action, err := report.ParseAction(name)
+		require.NoError(t, err)
+		require.Equal(t, expected, action)
+	}
+
+	_, err := report.ParseAction("abort")
+	require.Error(t, err)
+}
+
+func TestExit(t *testing.T) {
+	if os.Getenv("REPORT_EXIT") == "1" {
+		report.Exit(report.Event{Message: "Mutex timed out when acquiring lock"})
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestExit$")
+	cmd.Env = append(os.Environ(), "REPORT_EXIT=1")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	require.Equal(t, report.ExitCode, exitErr.ExitCode())
+	require.Contains(t, stderr.String(), "Mutex timed out when acquiring lock")
+	require.Contains(t, stderr.String(), "report_test.TestExit")
+}
+
+func TestFilterStacks(t *testing.T) {
+	dump := []byte("goroutine 1 [running]:\nmain.main()\n\n" +
+		"goroutine 12 [chan receive]:\nmain.worker()\n\n" +
+		"goroutine 130 [sync.Mutex.Lock]:\nmain.waiter()\n")
+
+	filtered := report.FilterStacks(dump, []uint64{1, 130, 7})
+	require.Equal(t, "goroutine 1 [running]:\nmain.main()\n\n"+
+		"goroutine 130 [sync.Mutex.Lock]:\nmain.waiter()", filtered)
+
+	require.Empty(t, report.FilterStacks(dump, nil))
+}
+
+func TestWithDump(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dumps")
+
+	block := make(chan struct{})
+	defer close(block)
+
+	go func() { <-block }()
+
+	e, err := report.WithDump(report.Event{}, dir, nil)
+	require.NoError(t, err)
+	require.Empty(t, e.Goroutines)
+
+	dump, err := os.ReadFile(e.DumpFile)
+	require.NoError(t, err)
+	require.Contains(t, string(dump), "TestWithDump.func")
+	require.Contains(t, e.String(), "all goroutines dumped to "+e.DumpFile)
+}
+
+func TestHistory(t *testing.T) {
+	require.Nil(t, report.NewHistory(0))
+
+	var disabled *report.History
+	disabled.Add(report.Record{Action: "lock"})
+	require.Empty(t, disabled.Records())
+
+	h := report.NewHistory(3)
+	for _, action := range []string{"lock", "unlock", "read lock", "read unlock"} {
+		h.Add(report.Record{Action: action})
+	}
+
+	records := h.Records()
+	require.Len(t, records, 3)
+	require.Equal(t, "unlock", records[0].Action)
+	require.Equal(t, "read unlock", records[2].Action)
+}
+
+func TestEventStringWithHistory(t *testing.T) {
+	e := report.Event{
+		Message: "Mutex timed out when acquiring lock",
+		History: []report.Record{
+			{Time: time.Date(2024, 1, 1, 15, 4, 5, 0, time.UTC), Goroutine: 2,
+				Action: "lock", Site: "main.f main.go:3"},
+		},
+	}
+
+	require.Equal(t, "Mutex timed out when acquiring lock\n\nhistory:\n"+
+		"15:04:05.000000 goroutine 2 lock at main.f main.go:3", e.String())
+}
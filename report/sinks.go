@@ -0,0 +1,79 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// LogReporter logs the events with a zerolog logger, at the error or warning
+// level depending on their severity.
+type LogReporter struct {
+	logger zerolog.Logger
+}
+
+// NewLogReporter creates a LogReporter using the given logger.
+func NewLogReporter(logger zerolog.Logger) *LogReporter {
+	return &LogReporter{logger: logger}
+}
+
+// Report logs the event.
+func (r *LogReporter) Report(e Event) {
+	level := zerolog.ErrorLevel
+	if e.Severity == SeverityWarning {
+		level = zerolog.WarnLevel
+	}
+
+	r.logger.WithLevel(level).
+		Str("kind", string(e.Kind)).
+		Str("name", e.Name).
+		Msg(e.String())
+}
+
+// JSONReporter writes the events as JSON, one per line.
+type JSONReporter struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+// NewJSONReporter creates a JSONReporter writing to w.
+func NewJSONReporter(w io.Writer) *JSONReporter {
+	return &JSONReporter{encoder: json.NewEncoder(w)}
+}
+
+// OpenJSONFile creates a JSONReporter appending to the file at the given
+// path, which is created if needed. It must be closed once done.
+func OpenJSONFile(path string) (*JSONReporter, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	r := NewJSONReporter(f)
+	r.closer = f
+
+	return r, nil
+}
+
+// Report writes the event. Write errors are ignored, as there is no one to
+// report them to.
+func (r *JSONReporter) Report(e Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_ = r.encoder.Encode(e)
+}
+
+// Close closes the underlying file, if the reporter has been created by
+// OpenJSONFile.
+func (r *JSONReporter) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+
+	return r.closer.Close()
+}
@@ -0,0 +1,106 @@
+package sync
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"go.dedis.ch/debugtools/internal/goroutine"
+	"go.dedis.ch/debugtools/report"
+)
+
+// OnAssertion is the action taken in the calling goroutine when an assertion
+// of the debug locks, such as Mutex.AssertHeld, fails, once the event has
+// been reported.
+var OnAssertion = report.ActionLog
+
+// HeldLock is a debug lock held by a goroutine.
+type HeldLock struct {
+	// Name is the name given to the lock with WithName, or a generated one.
+	Name string
+	// Read tells whether the lock is held for reading.
+	Read bool
+	// Rank is the rank given to the lock with WithRank, or zero.
+	Rank int
+	// Stack is where the lock has been acquired.
+	Stack string
+}
+
+// HeldLocks returns the debug locks held by the calling goroutine, in
+// acquisition order. It returns nil when debugging is off.
+func HeldLocks() []HeldLock {
+	if !DebugIsOn {
+		return nil
+	}
+
+	order.mutex.Lock()
+	defer order.mutex.Unlock()
+
+	var locks []HeldLock
+	for _, h := range order.held[goroutine.ID()] {
+		locks = append(locks, HeldLock{
+			Name:  h.name,
+			Read:  h.read,
+			Rank:  h.rank,
+			Stack: string(h.stack),
+		})
+	}
+
+	return locks
+}
+
+// holding returns the lock id if it is held by the goroutine gid, giving
+// preference to a write lock.
+func (o *lockOrder) holding(gid, id uint64) (heldLock, bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	var lock heldLock
+	found := false
+
+	for _, h := range o.held[gid] {
+		if h.id == id && (!found || !h.read) {
+			lock, found = h, true
+		}
+	}
+
+	return lock, found
+}
+
+// assertHeld checks that the calling goroutine holds the lock id of the given
+// kind and name, for writing unless read is set.
+func assertHeld(c *config, kind, name string, id uint64, read bool) {
+	gid := goroutine.ID()
+
+	held, found := order.holding(gid, id)
+	switch {
+	case !found:
+		failAssertion(c, kind, name, fmt.Sprintf("assertion failed: %s %s not held", kind, name), gid, nil)
+	case held.read && !read:
+		msg := fmt.Sprintf("assertion failed: %s %s held for reading only", kind, name)
+		failAssertion(c, kind, name, msg, gid, &held)
+	}
+}
+
+// assertNotHeld checks that the calling goroutine does not hold the lock id of
+// the given kind and name.
+func assertNotHeld(c *config, kind, name string, id uint64) {
+	gid := goroutine.ID()
+
+	held, found := order.holding(gid, id)
+	if found {
+		failAssertion(c, kind, name, fmt.Sprintf("assertion failed: %s %s held", kind, name), gid, &held)
+	}
+}
+
+// failAssertion reports the failed assertion of the goroutine gid, with the
+// lock it holds if any, and takes the OnAssertion action.
+func failAssertion(c *config, kind, name, msg string, gid uint64, held *heldLock) {
+	e := newEvent(report.KindAssertion, kind, name, msg, gid, debug.Stack())
+	if held != nil {
+		e = withHolder(e, gid, *held)
+	}
+
+	action := OnAssertion
+	c.emit(e)
+	takeAction(action, e)
+}
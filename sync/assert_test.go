@@ -0,0 +1,116 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.dedis.ch/debugtools/report"
+)
+
+// assertionFailure returns what f panicked with, or an empty string.
+func assertionFailure(f func()) (out string) {
+	defer func() {
+		if r := recover(); r != nil {
+			out = fmt.Sprint(r)
+		}
+	}()
+
+	f()
+
+	return ""
+}
+
+func TestAssertionsDebugOff(t *testing.T) {
+	DebugIsOn = false
+
+	var m Mutex
+	var rw RWMutex
+
+	m.AssertHeld()
+	rw.AssertHeld()
+	rw.AssertRHeld()
+
+	m.Lock()
+	m.AssertNotHeld()
+	m.Unlock()
+
+	if locks := HeldLocks(); locks != nil {
+		t.Fatalf("unexpected held locks: %v", locks)
+	}
+}
+
+func TestAssertionsDebugOn(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+
+	original := OnAssertion
+	OnAssertion = report.ActionPanic
+	defer func() { OnAssertion = original }()
+
+	m := NewMutex(WithName("state"))
+	rw := NewRWMutex(WithName("peers"), WithRank(2))
+
+	out := assertionFailure(m.AssertHeld)
+	if !strings.Contains(out, "assertion failed: Mutex state not held") {
+		t.Fatalf("unexpected failure: %q", out)
+	}
+
+	m.Lock()
+	rw.RLock()
+
+	if out := assertionFailure(func() { m.AssertHeld(); rw.AssertRHeld() }); out != "" {
+		t.Fatalf("unexpected failure: %s", out)
+	}
+
+	out = assertionFailure(rw.AssertHeld)
+	if !strings.Contains(out, "assertion failed: RWMutex peers held for reading only") {
+		t.Fatalf("unexpected failure: %q", out)
+	}
+
+	out = assertionFailure(m.AssertNotHeld)
+	if !strings.Contains(out, "assertion failed: Mutex state held") ||
+		!strings.Contains(out, "lock already held by goroutine") {
+		t.Fatalf("unexpected failure: %q", out)
+	}
+
+	locks := HeldLocks()
+	if len(locks) != 2 || locks[0].Name != "state" || locks[1].Name != "peers" ||
+		!locks[1].Read || locks[1].Rank != 2 {
+		t.Fatalf("unexpected held locks: %v", locks)
+	}
+
+	// the locks are held by this goroutine only
+	done := make(chan string)
+	go func() { done <- assertionFailure(rw.AssertRHeld) }()
+	if out := <-done; !strings.Contains(out, "not held") {
+		t.Fatalf("unexpected failure: %q", out)
+	}
+
+	rw.RUnlock()
+	m.Unlock()
+
+	if out := assertionFailure(func() { m.AssertNotHeld(); rw.AssertNotHeld() }); out != "" {
+		t.Fatalf("unexpected failure: %s", out)
+	}
+	if locks := HeldLocks(); len(locks) != 0 {
+		t.Fatalf("unexpected held locks: %v", locks)
+	}
+}
+
+func TestAssertionLogged(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+
+	var collector report.Collector
+	m := NewMutex(WithName("logged"), WithReporter(&collector))
+
+	if out := assertionFailure(m.AssertHeld); out != "" {
+		t.Fatalf("unexpected panic: %s", out)
+	}
+
+	events := collector.Events()
+	if len(events) != 1 || events[0].Kind != report.KindAssertion {
+		t.Fatalf("unexpected reports: %v", events)
+	}
+}
@@ -1,11 +1,206 @@
 package sync
 
 import (
-	_sync "sync"
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"go.dedis.ch/debugtools/internal/goroutine"
+	"go.dedis.ch/debugtools/report"
 )
 
 // Cond implements a condition variable, a rendezvous point for goroutines
 // waiting for or announcing the occurrence of an event.
+//
+// Each Cond has an associated Locker L (often a *Mutex or *RWMutex), which
+// must be held when changing the condition and when calling the Wait method.
+//
+// It wraps the Cond of the standard library. When debugging is on, a
+// goroutine waiting for longer than the timeout is reported, with the number
+// of waiters and the last Signal or Broadcast.
+//
+// A Cond must not be copied after first use.
 type Cond struct {
-	_sync.Cond
+	// L is held while observing or changing the condition.
+	L Locker
+
+	cond sync.Cond
+	// bind gives L to cond on first use.
+	bind  sync.Once
+	mutex sync.Mutex
+	// notified is the time of the last Signal or Broadcast, as described by
+	// notification, recorded when debugging is on.
+	notified     time.Time
+	notification string
+	id           uint64
+	tracker      tracker
+	cfg          *config
+}
+
+// NewCond returns a new Cond with Locker l, configured with the given
+// options.
+func NewCond(l Locker, opts ...Option) *Cond {
+	return &Cond{L: l, cfg: newConfig(opts)}
+}
+
+// Wait atomically unlocks c.L and suspends execution of the calling
+// goroutine. After later resuming execution, Wait locks c.L before
+// returning. Unlike in other systems, Wait cannot return unless awoken by
+// Broadcast or Signal.
+//
+// Because c.L is not locked while Wait is waiting, the caller typically
+// cannot assume that the condition is true when Wait returns. Instead, the
+// caller should Wait in a loop.
+func (c *Cond) Wait() {
+	if DebugIsOn {
+		_ = c.debugWait(context.Background())
+	} else {
+		_ = c.wait(context.Background())
+	}
+}
+
+// WaitContext is Wait, unless ctx is done before the calling goroutine is
+// awoken. In that case, c.L is locked again and the error of the context is
+// returned. The other goroutines waiting on c are then awoken too, as by
+// Broadcast.
+func (c *Cond) WaitContext(ctx context.Context) error {
+	if DebugIsOn {
+		return c.debugWait(ctx)
+	}
+
+	return c.wait(ctx)
+}
+
+func (c *Cond) debugWait(ctx context.Context) error {
+	c.cfg.getLogger().Debug().Str("name", c.name()).Msg("Waiting")
+	gid, stack := goroutine.ID(), debug.Stack()
+	c.track().wait(gid, modeWait, stack)
+
+	ctx, abort := waitContext(ctx)
+	if abort != nil {
+		defer abort(nil)
+	}
+
+	e := c.event(report.KindTimeout, "Cond timed out waiting for a signal", gid, stack)
+	waiting := startWaitTimer(c.cfg, e, &c.tracker, abort)
+	err := c.wait(ctx)
+	close(waiting)
+
+	c.tracker.stopWaiting(gid, modeWait)
+	if err != nil {
+		panicOnTimeout(ctx)
+	}
+
+	return err
+}
+
+// wait unlocks c.L until the calling goroutine is awoken or ctx is done, in
+// which case the error of the context is returned.
+func (c *Cond) wait(ctx context.Context) error {
+	if ctx.Done() == nil {
+		c.std().Wait()
+		return nil
+	}
+
+	// c.L is locked to broadcast, so that the goroutine is waiting already
+	broadcast := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		defer close(broadcast)
+
+		c.L.Lock()
+		defer c.L.Unlock()
+
+		c.std().Broadcast()
+	})
+
+	c.std().Wait()
+
+	if !stop() {
+		// the broadcast is not left behind, as it locks c.L
+		c.L.Unlock()
+		<-broadcast
+		c.L.Lock()
+	}
+
+	return ctx.Err()
+}
+
+// std returns the condition variable of the standard library, bound to c.L.
+func (c *Cond) std() *sync.Cond {
+	c.bind.Do(func() { c.cond.L = c.L })
+	return &c.cond
+}
+
+// Signal wakes one goroutine waiting on c, if there is any.
+//
+// It is allowed but not required for the caller to hold c.L
+// during the call.
+func (c *Cond) Signal() {
+	c.notify("signal", false)
+}
+
+// Broadcast wakes all goroutines waiting on c.
+//
+// It is allowed but not required for the caller to hold c.L
+// during the call.
+func (c *Cond) Broadcast() {
+	c.notify("broadcast", true)
+}
+
+// notify wakes one waiter, or all of them, and records the notification
+// when debugging is on.
+func (c *Cond) notify(notification string, all bool) {
+	if !DebugIsOn {
+		if all {
+			c.std().Broadcast()
+		} else {
+			c.std().Signal()
+		}
+
+		return
+	}
+
+	woken := len(c.tracker.waiting(modeWait))
+	if all {
+		c.std().Broadcast()
+	} else {
+		woken = min(woken, 1)
+		c.std().Signal()
+	}
+
+	c.mutex.Lock()
+	c.notified = time.Now()
+	c.notification = notification
+	c.mutex.Unlock()
+
+	c.cfg.getLogger().Debug().Str("name", c.name()).Int("woken", woken).Msg(notification)
+	c.track().record(goroutine.ID(), fmt.Sprintf("%s to %d waiter(s)", notification, woken))
+	logTrace("Cond", c.name(), notification)
+}
+
+func (c *Cond) name() string {
+	return c.cfg.nameOr(lockName("Cond", lazyLockID(&c.id)))
+}
+
+func (c *Cond) event(kind report.Kind, msg string, gid uint64, stack []byte) report.Event {
+	return newEvent(kind, "Cond", c.name(), msg, gid, stack)
+}
+
+func (c *Cond) track() *tracker {
+	c.tracker.identify("Cond", c.name(), c.status, c.cfg.getHistorySize())
+	return &c.tracker
+}
+
+// status describes the last Signal or Broadcast.
+func (c *Cond) status() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.notified.IsZero() {
+		return "never signaled"
+	}
+
+	return fmt.Sprintf("last %s %v ago", c.notification, time.Since(c.notified).Round(time.Millisecond))
 }
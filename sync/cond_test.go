@@ -0,0 +1,273 @@
+// This file is adapted from the GO sync package.
+// It originally contains the following license:
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sync
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.dedis.ch/debugtools/report"
+)
+
+func testCondSignal(t *testing.T) {
+	var m Mutex
+	c := NewCond(&m)
+	n := 2
+	running := make(chan bool, n)
+	awake := make(chan bool, n)
+	exited := make(chan bool, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			m.Lock()
+			running <- true
+			c.Wait()
+			awake <- true
+			m.Unlock()
+			exited <- true
+		}()
+	}
+	for i := 0; i < n; i++ {
+		<-running // Wait for everyone to run.
+	}
+	for n > 0 {
+		select {
+		case <-awake:
+			t.Fatal("goroutine not asleep")
+		default:
+		}
+		m.Lock()
+		c.Signal()
+		m.Unlock()
+		<-awake // Will deadlock if no goroutine wakes up
+		select {
+		case <-awake:
+			t.Fatal("too many goroutines awake")
+		default:
+		}
+		n--
+	}
+	c.Signal()
+
+	// the goroutines read DebugIsOn until they are done
+	for i := 0; i < cap(exited); i++ {
+		<-exited
+	}
+}
+
+func TestCondSignalDebugOff(t *testing.T) {
+	DebugIsOn = false
+	testCondSignal(t)
+}
+
+func TestCondSignalDebugOn(t *testing.T) {
+	DebugIsOn = true
+	testCondSignal(t)
+}
+
+func testCondBroadcast(t *testing.T) {
+	var m Mutex
+	c := NewCond(&m)
+	n := 50
+	running := make(chan int, n)
+	awake := make(chan int, n)
+	exited := make(chan bool, n)
+	exit := false
+	for i := 0; i < n; i++ {
+		go func(g int) {
+			m.Lock()
+			for !exit {
+				running <- g
+				c.Wait()
+				awake <- g
+			}
+			m.Unlock()
+			exited <- true
+		}(i)
+	}
+	for i := 0; i < n; i++ {
+		for i := 0; i < n; i++ {
+			<-running // Will deadlock unless n are running.
+		}
+		if i == n-1 {
+			m.Lock()
+			exit = true
+			m.Unlock()
+		}
+		select {
+		case <-awake:
+			t.Fatal("goroutine not asleep")
+		default:
+		}
+		m.Lock()
+		c.Broadcast()
+		m.Unlock()
+		seen := make([]bool, n)
+		for i := 0; i < n; i++ {
+			g := <-awake
+			if seen[g] {
+				t.Fatal("goroutine woke up twice")
+			}
+			seen[g] = true
+		}
+	}
+	select {
+	case <-running:
+		t.Fatal("goroutine did not exit")
+	default:
+	}
+	c.Broadcast()
+
+	for i := 0; i < n; i++ {
+		<-exited
+	}
+}
+
+func TestCondBroadcastDebugOff(t *testing.T) {
+	DebugIsOn = false
+	testCondBroadcast(t)
+}
+
+func TestCondBroadcastDebugOn(t *testing.T) {
+	DebugIsOn = true
+	testCondBroadcast(t)
+}
+
+func testCondWaitContext(t *testing.T) {
+	var m Mutex
+	c := NewCond(&m)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	m.Lock()
+	err := c.WaitContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.TryLock() {
+		t.Fatal("lock not held after WaitContext")
+	}
+	m.Unlock()
+
+	// a waiter is still signaled after one has given up
+	running := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		m.Lock()
+		close(running)
+		err := c.WaitContext(context.Background())
+		m.Unlock()
+		done <- err
+	}()
+
+	<-running
+	m.Lock()
+	c.Signal()
+	m.Unlock()
+
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCondWaitContextDebugOff(t *testing.T) {
+	DebugIsOn = false
+	testCondWaitContext(t)
+}
+
+func TestCondWaitContextDebugOn(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+	testCondWaitContext(t)
+}
+
+func TestCondTimeout(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+
+	var collector report.Collector
+	var m Mutex
+	c := NewCond(&m, WithName("ready"), WithTimeout(10*time.Millisecond), WithReporter(&collector))
+
+	c.Broadcast()
+
+	m.Lock()
+	time.AfterFunc(50*time.Millisecond, c.Signal)
+	c.Wait()
+	m.Unlock()
+
+	var e report.Event
+	for _, event := range collector.Events() {
+		if event.Kind == report.KindTimeout {
+			e = event
+		}
+	}
+
+	if !strings.HasPrefix(e.Message, "Cond timed out waiting for a signal (last broadcast ") ||
+		!strings.HasSuffix(e.Message, " ago, 1 waiter(s))") {
+		t.Fatalf("unexpected report: %v", e)
+	}
+	if len(e.History) == 0 || e.History[0].Action != "broadcast to 0 waiter(s)" {
+		t.Fatalf("unexpected history: %v", e.History)
+	}
+}
+
+func TestCondPanicOnTimeout(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+	setupTimeout(t, 10*time.Millisecond)
+	setupOnTimeout(t, report.ActionPanic)
+
+	var m Mutex
+	c := NewCond(&m)
+
+	out := recoverFrom(func() {
+		m.Lock()
+		c.Wait()
+	})
+	m.Unlock()
+
+	if !strings.Contains(out, "Cond timed out waiting for a signal (never signaled, 1 waiter(s))") {
+		t.Fatalf("unexpected panic: %s", out)
+	}
+	if waiters := c.tracker.waiting(modeWait); len(waiters) != 0 {
+		t.Fatalf("waiter not removed: %v", waiters)
+	}
+}
+
+func TestCondWaitAllocsDebugOff(t *testing.T) {
+	DebugIsOn = false
+
+	var m Mutex
+	c := NewCond(&m)
+
+	var stopped atomic.Bool
+	signaled := make(chan struct{})
+	go func() {
+		defer close(signaled)
+		for !stopped.Load() {
+			c.Signal()
+			runtime.Gosched()
+		}
+	}()
+
+	m.Lock()
+	allocs := testing.AllocsPerRun(100, c.Wait)
+	m.Unlock()
+
+	stopped.Store(true)
+	<-signaled
+
+	if allocs != 0 {
+		t.Fatalf("want 0 allocations per Wait, got %v", allocs)
+	}
+}
@@ -0,0 +1,157 @@
+package sync
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.dedis.ch/debugtools/report"
+)
+
+// Reporter receives the events of the primitives which are not configured
+// with WithReporter. When nil, the events are logged with the logger of the
+// primitive.
+var Reporter report.Reporter
+
+// Option configures a debug primitive created with one of the constructors
+// of the package, such as NewMutex.
+type Option func(*config)
+
+// config is the per-instance configuration of a debug primitive. A nil
+// config uses the package defaults.
+type config struct {
+	name     string
+	timeout  time.Duration
+	logger   *zerolog.Logger
+	reporter report.Reporter
+	history  *int
+	rank     int
+}
+
+// WithName gives a name to the primitive, used in every log line and report
+// about it instead of a generated one.
+func WithName(name string) Option {
+	return func(c *config) {
+		c.name = name
+	}
+}
+
+// WithTimeout overrides the global Timeout for the primitive.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.timeout = timeout
+	}
+}
+
+// WithLogger overrides the global Logger for the primitive.
+func WithLogger(logger zerolog.Logger) Option {
+	return func(c *config) {
+		c.logger = &logger
+	}
+}
+
+// WithReporter overrides the global Reporter for the primitive.
+func WithReporter(reporter report.Reporter) Option {
+	return func(c *config) {
+		c.reporter = reporter
+	}
+}
+
+// WithHistory overrides the global HistorySize for the primitive. A size of
+// zero disables its history.
+func WithHistory(size int) Option {
+	return func(c *config) {
+		c.history = &size
+	}
+}
+
+// WithRank gives a rank to a lock, to enforce a lock hierarchy. While
+// debugging is on, acquiring a lock of a lower rank than one already held by
+// the goroutine is reported. Locks without a rank, or of rank zero, are not
+// checked.
+func WithRank(rank int) Option {
+	return func(c *config) {
+		c.rank = rank
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// nameOr returns the configured name, or the given default one.
+func (c *config) nameOr(name string) string {
+	if c == nil || c.name == "" {
+		return name
+	}
+
+	return c.name
+}
+
+func (c *config) getTimeout() time.Duration {
+	if c == nil || c.timeout == 0 {
+		return Timeout
+	}
+
+	return c.timeout
+}
+
+func (c *config) getLogger() *zerolog.Logger {
+	if c == nil || c.logger == nil {
+		return &Logger
+	}
+
+	return c.logger
+}
+
+func (c *config) getHistorySize() int {
+	if c == nil || c.history == nil {
+		return HistorySize
+	}
+
+	return *c.history
+}
+
+func (c *config) getRank() int {
+	if c == nil {
+		return 0
+	}
+
+	return c.rank
+}
+
+// emit sends the event to the reporter of the primitive.
+func (c *config) emit(e report.Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	statistics.count(c, e)
+
+	switch {
+	case c != nil && c.reporter != nil:
+		c.reporter.Report(e)
+	case Reporter != nil:
+		Reporter.Report(e)
+	default:
+		report.NewLogReporter(*c.getLogger()).Report(e)
+	}
+}
+
+// newEvent creates an event about the primitive name of the given type,
+// triggered by the goroutine gid from the given stack.
+func newEvent(kind report.Kind, primitive, name, msg string, gid uint64, stack []byte) report.Event {
+	return report.Event{
+		Kind:      kind,
+		Severity:  report.SeverityError,
+		Primitive: primitive,
+		Name:      name,
+		Message:   msg,
+		Goroutine: gid,
+		Stack:     string(stack),
+	}
+}
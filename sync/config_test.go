@@ -0,0 +1,107 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestNamedMutexTimeout(t *testing.T) {
+	DebugIsOn = true
+	global := setupLogger(t)
+
+	b := new(logBuffer)
+	m := NewMutex(WithName("peer-table"), WithTimeout(10*time.Millisecond),
+		WithLogger(zerolog.New(b)))
+
+	m.Lock()
+	time.Sleep(50 * time.Millisecond)
+	m.Unlock()
+
+	out := b.String()
+	if !strings.Contains(out, `"name":"peer-table"`) {
+		t.Fatalf("name not logged: %s", out)
+	}
+	if !strings.Contains(out, "Mutex timed out before releasing lock") {
+		t.Fatalf("timeout not logged: %s", out)
+	}
+	if !strings.Contains(out, `"message":"Locking"`) {
+		t.Fatalf("debug message not logged: %s", out)
+	}
+	if global.Len() != 0 {
+		t.Fatalf("global logger used: %s", global.String())
+	}
+}
+
+func TestNamedRWMutexLogger(t *testing.T) {
+	DebugIsOn = true
+	global := setupLogger(t)
+
+	// the dump fails in a directory which is a file
+	original := DumpDir
+	DumpDir = filepath.Join(t.TempDir(), "file")
+	defer func() { DumpDir = original }()
+
+	err := os.WriteFile(DumpDir, nil, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := new(logBuffer)
+	m := NewRWMutex(WithName("peer-index"), WithTimeout(10*time.Millisecond),
+		WithLogger(zerolog.New(b)))
+
+	m.RLock()
+	m.RUnlock()
+	m.Lock()
+	time.Sleep(50 * time.Millisecond)
+	m.Unlock()
+
+	out := b.String()
+	for _, msg := range []string{"Read locking", "Locking", "failed to dump goroutines"} {
+		if !strings.Contains(out, `"message":"`+msg+`"`) {
+			t.Fatalf("%q not logged: %s", msg, out)
+		}
+	}
+	if global.Len() != 0 {
+		t.Fatalf("global logger used: %s", global.String())
+	}
+}
+
+func TestNamedLockOrder(t *testing.T) {
+	DebugIsOn = true
+	l := setupLogger(t)
+
+	state := NewRWMutex(WithName("state"))
+	peers := NewMutex(WithName("peers"))
+
+	state.Lock()
+	peers.Lock()
+	peers.Unlock()
+	state.Unlock()
+
+	peers.Lock()
+	state.RLock()
+	state.RUnlock()
+	peers.Unlock()
+
+	if !strings.Contains(l.String(), "peers -> state -> peers") {
+		t.Fatalf("names not reported: %s", l.String())
+	}
+}
+
+func TestDefaultName(t *testing.T) {
+	var wg WaitGroup
+	if !strings.HasPrefix(wg.name(), "WaitGroup#") {
+		t.Fatalf("unexpected name: %s", wg.name())
+	}
+
+	wg = *NewWaitGroup(WithName("workers"))
+	if wg.name() != "workers" {
+		t.Fatalf("unexpected name: %s", wg.name())
+	}
+}
@@ -0,0 +1,89 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// LockError is returned when a lock could not be acquired before the given
+// context was done.
+type LockError struct {
+	// Name is the name of the lock.
+	Name string
+	// Err is the error of the context.
+	Err error
+	// Holders describes the goroutines holding the lock when giving up. It is
+	// only available when debugging is on.
+	Holders string
+}
+
+func (e *LockError) Error() string {
+	if e.Holders == "" {
+		return fmt.Sprintf("failed to acquire %s: %v", e.Name, e.Err)
+	}
+
+	return fmt.Sprintf("failed to acquire %s: %v\n%s", e.Name, e.Err, e.Holders)
+}
+
+// Unwrap returns the error of the context.
+func (e *LockError) Unwrap() error {
+	return e.Err
+}
+
+// Lock acquisition states of acquireContext.
+const (
+	pending int32 = iota
+	acquired
+	abandoned
+)
+
+// acquireContext acquires a lock with lock, unless ctx is done first in which
+// case the error of the context is returned. The lock is first tried with
+// tryLock, and if it is abandoned while a goroutine is blocked acquiring it,
+// it is released with unlock as soon as that goroutine gets it. Until then,
+// the abandoned acquisition is still queued, e.g. a pending writer still
+// excludes new readers from a RWMutex.
+func acquireContext(ctx context.Context, tryLock func() bool, lock, unlock func()) error {
+	if tryLock() {
+		return nil
+	}
+
+	if ctx.Done() == nil {
+		lock()
+		return nil
+	}
+
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	var state int32
+	done := make(chan struct{})
+
+	go func() {
+		lock()
+
+		if !atomic.CompareAndSwapInt32(&state, pending, acquired) {
+			unlock()
+			return
+		}
+
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		if atomic.CompareAndSwapInt32(&state, pending, abandoned) {
+			return ctx.Err()
+		}
+
+		// the lock has been acquired in the meantime
+		<-done
+
+		return nil
+	}
+}
@@ -0,0 +1,136 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitAbandoned is a helper function waiting until the abandoned lock
+// acquisitions have released the lock.
+func waitAbandoned(t *testing.T, tryLock func() bool, unlock func()) {
+	deadline := time.Now().Add(time.Second)
+	for !tryLock() {
+		if time.Now().After(deadline) {
+			t.Fatal("abandoned acquisition did not release the lock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	unlock()
+}
+
+func lockContext(t *testing.T) {
+	var m Mutex
+
+	m.Lock()
+
+	err := m.LockTimeout(10 * time.Millisecond)
+	var lockErr *LockError
+	if !errors.As(err, &lockErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if DebugIsOn && !strings.Contains(lockErr.Holders, "lock held by goroutine") {
+		t.Fatalf("holders not reported: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	err = m.LockContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m.Unlock()
+	waitAbandoned(t, m.TryLock, m.Unlock)
+
+	m.Lock()
+	start := time.Now()
+	time.AfterFunc(10*time.Millisecond, m.Unlock)
+
+	err = m.LockTimeout(time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if time.Since(start) < 5*time.Millisecond {
+		t.Fatal("LockTimeout did not wait for the lock")
+	}
+	m.Unlock()
+}
+
+func TestLockContextDebugOff(t *testing.T) {
+	DebugIsOn = false
+	lockContext(t)
+}
+
+func TestLockContextDebugOn(t *testing.T) {
+	DebugIsOn = true
+	lockContext(t)
+}
+
+func rLockContext(t *testing.T) {
+	var m RWMutex
+
+	m.Lock()
+
+	err := m.RLockTimeout(10 * time.Millisecond)
+	var lockErr *LockError
+	if !errors.As(err, &lockErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if DebugIsOn && !strings.Contains(lockErr.Holders, "lock held by goroutine") {
+		t.Fatalf("holders not reported: %v", err)
+	}
+
+	err = m.LockTimeout(10 * time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m.Unlock()
+	waitAbandoned(t, m.TryLock, m.Unlock)
+
+	err = m.RLockContext(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		err := m.RLockTimeout(time.Second)
+		if err == nil {
+			m.RUnlock()
+		}
+		done <- err
+	}()
+
+	err = <-done
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = m.LockTimeout(10 * time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m.RUnlock()
+
+	err = m.LockContext(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m.Unlock()
+}
+
+func TestRLockContextDebugOff(t *testing.T) {
+	DebugIsOn = false
+	rLockContext(t)
+}
+
+func TestRLockContextDebugOn(t *testing.T) {
+	DebugIsOn = true
+	rLockContext(t)
+}
@@ -0,0 +1,86 @@
+package sync
+
+import (
+	"expvar"
+	"sync"
+	"time"
+
+	"go.dedis.ch/debugtools/registry"
+)
+
+// expvarSummary summarizes a histogram for expvar.
+type expvarSummary struct {
+	Count uint64        `json:"count"`
+	Mean  time.Duration `json:"mean"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+type expvarLock struct {
+	Kind string        `json:"kind"`
+	Name string        `json:"name"`
+	Wait expvarSummary `json:"wait"`
+	Hold expvarSummary `json:"hold"`
+}
+
+var publishOnce sync.Once
+
+// PublishExpvar publishes the state of the package with expvar, and enables
+// the registry:
+//
+//   - debugtools.sync.locks lists the primitives held or waited on,
+//   - debugtools.sync.stats summarizes the wait and hold times of the locks,
+//   - debugtools.sync.events counts the events, such as timeouts,
+//   - debugtools.sync.waitgroups gives the counters of the wait groups,
+//   - debugtools.sync.pools gives the statistics of the pools.
+//
+// It can be called several times, and enables the registry again each time.
+func PublishExpvar() {
+	registry.Enable()
+
+	publishOnce.Do(func() {
+		expvar.Publish("debugtools.sync.locks", expvar.Func(func() any {
+			return registry.ListFunc(func(key any) bool {
+				_, ok := key.(*tracker)
+				return ok
+			})
+		}))
+
+		expvar.Publish("debugtools.sync.stats", expvar.Func(func() any {
+			stats := Stats()
+
+			locks := make([]expvarLock, len(stats))
+			for i, s := range stats {
+				locks[i] = expvarLock{
+					Kind: s.Kind,
+					Name: s.Name,
+					Wait: summarize(s.Wait),
+					Hold: summarize(s.Hold),
+				}
+			}
+
+			return locks
+		}))
+
+		expvar.Publish("debugtools.sync.events", expvar.Func(func() any {
+			return EventCounts()
+		}))
+
+		expvar.Publish("debugtools.sync.waitgroups", expvar.Func(func() any {
+			return WaitGroupCounters()
+		}))
+
+		expvar.Publish("debugtools.sync.pools", expvar.Func(func() any {
+			return Pools()
+		}))
+	})
+}
+
+func summarize(h Histogram) expvarSummary {
+	return expvarSummary{
+		Count: h.Count,
+		Mean:  h.Mean(),
+		P99:   h.Quantile(0.99),
+		Max:   h.Max,
+	}
+}
@@ -0,0 +1,74 @@
+package sync
+
+import (
+	"expvar"
+	"strings"
+	"testing"
+
+	"go.dedis.ch/debugtools/internal/testutil"
+	"go.dedis.ch/debugtools/registry"
+)
+
+func TestPublishExpvarDebugOff(t *testing.T) {
+	DebugIsOn = false
+	PublishExpvar()
+	PublishExpvar()
+	defer registry.Disable()
+
+	wg := NewWaitGroup(WithName("expvar-off"))
+	wg.Add(1)
+	defer wg.Done()
+
+	out := expvar.Get("debugtools.sync.waitgroups").String()
+	if !strings.Contains(out, `{"Name":"expvar-off","Counter":1}`) {
+		t.Fatalf("wait group not published: %s", out)
+	}
+
+	name := testutil.UniqueName(t)
+	p := NewPool(func() any { return new(buffer) }, WithName(name))
+	_ = p.Get()
+
+	out = expvar.Get("debugtools.sync.pools").String()
+	if !strings.Contains(out, `"Name":"`+name+`","Hits":0,"Misses":1,"Allocations":1`) {
+		t.Fatalf("pool not published: %s", out)
+	}
+}
+
+func TestPublishExpvarDebugOn(t *testing.T) {
+	DebugIsOn = true
+	PublishExpvar()
+	registry.Enable()
+	defer registry.Disable()
+
+	m := NewMutex(WithName("expvar-on"))
+	m.Lock()
+
+	out := expvar.Get("debugtools.sync.locks").String()
+	if !strings.Contains(out, `"name":"expvar-on"`) {
+		t.Fatalf("lock not published: %s", out)
+	}
+
+	m.Unlock()
+
+	once := NewOnce(WithName("expvar-once"))
+	once.Do(func() {
+		out = expvar.Get("debugtools.sync.locks").String()
+	})
+	if !strings.Contains(out, `"kind":"Once","name":"expvar-once"`) {
+		t.Fatalf("once not published: %s", out)
+	}
+
+	out = expvar.Get("debugtools.sync.stats").String()
+	if !strings.Contains(out, `"name":"expvar-on"`) {
+		t.Fatalf("stats not published: %s", out)
+	}
+
+	name := testutil.UniqueName(t)
+	p := NewPool(func() any { return new(buffer) }, WithName(name))
+	_ = p.Get()
+
+	out = expvar.Get("debugtools.sync.pools").String()
+	if !strings.Contains(out, `"Name":"`+name+`","Hits":0,"Misses":1,"Allocations":1`) {
+		t.Fatalf("pool not published: %s", out)
+	}
+}
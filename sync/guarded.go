@@ -0,0 +1,147 @@
+package sync
+
+import (
+	"bytes"
+	"runtime/debug"
+	"sync/atomic"
+	"unsafe"
+
+	"go.dedis.ch/debugtools/internal/goroutine"
+	"go.dedis.ch/debugtools/report"
+)
+
+// Guarded is a value which can only be accessed while holding its Mutex.
+// The zero value is ready to use, and holds the zero value of T.
+//
+// When debugging is on, the hold times of the accesses are recorded per call
+// site as the ones of the Mutex, and a value modified outside of the lock,
+// through a reference which escaped an access, is reported at the next
+// access. The detection compares the memory of the value itself, padding
+// included, with a copy taken when the lock was last released for writing:
+// the changes to the data its pointers, slices or maps refer to are not seen,
+// and a value assigned as a whole outside of the lock may be reported because
+// of its padding bytes, even if none of its fields changed.
+//
+// A Guarded must not be copied after first use.
+type Guarded[T any] struct {
+	mutex Mutex
+	value T
+	// snapshot is a copy of the memory of the value when it was last
+	// modified.
+	snapshot atomic.Pointer[[]byte]
+}
+
+// NewGuarded creates a Guarded holding value, with a Mutex configured with
+// the given options.
+func NewGuarded[T any](value T, opts ...Option) *Guarded[T] {
+	return &Guarded[T]{mutex: Mutex{cfg: newConfig(opts)}, value: value}
+}
+
+// With calls f with a pointer to the value, while holding the lock. The
+// pointer must not be used once f returns.
+func (g *Guarded[T]) With(f func(*T)) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	checkSnapshot(g.mutex.cfg, "Guarded", g.mutex.name(), &g.value, &g.snapshot)
+	defer takeSnapshot(&g.value, &g.snapshot)
+
+	f(&g.value)
+}
+
+// Read calls f with a copy of the value, while holding the lock.
+func (g *Guarded[T]) Read(f func(T)) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	checkSnapshot(g.mutex.cfg, "Guarded", g.mutex.name(), &g.value, &g.snapshot)
+
+	f(g.value)
+}
+
+// RWGuarded is a value which can only be accessed while holding its RWMutex,
+// for reading or writing. The zero value is ready to use, and holds the zero
+// value of T.
+//
+// As with Guarded, when debugging is on, the hold times of the accesses are
+// recorded per call site, and a value modified outside of the lock is
+// reported at the next access.
+//
+// An RWGuarded must not be copied after first use.
+type RWGuarded[T any] struct {
+	mutex RWMutex
+	value T
+	// snapshot is a copy of the memory of the value when it was last
+	// modified.
+	snapshot atomic.Pointer[[]byte]
+}
+
+// NewRWGuarded creates an RWGuarded holding value, with an RWMutex configured
+// with the given options.
+func NewRWGuarded[T any](value T, opts ...Option) *RWGuarded[T] {
+	return &RWGuarded[T]{mutex: RWMutex{cfg: newConfig(opts)}, value: value}
+}
+
+// With calls f with a pointer to the value, while holding the lock for
+// writing. The pointer must not be used once f returns.
+func (g *RWGuarded[T]) With(f func(*T)) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	checkSnapshot(g.mutex.cfg, "RWGuarded", g.mutex.name(), &g.value, &g.snapshot)
+	defer takeSnapshot(&g.value, &g.snapshot)
+
+	f(&g.value)
+}
+
+// Read calls f with a copy of the value, while holding the lock for reading.
+// Several goroutines can read the value at the same time.
+func (g *RWGuarded[T]) Read(f func(T)) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	checkSnapshot(g.mutex.cfg, "RWGuarded", g.mutex.name(), &g.value, &g.snapshot)
+
+	f(g.value)
+}
+
+// checkSnapshot reports that the value of the guarded primitive of the given
+// kind and name has been modified outside of its lock, if its memory differs
+// from its snapshot. The memory is compared rather than the values, since
+// func fields or NaN floats are never equal to themselves, which makes the
+// padding bytes part of the comparison. The snapshot is
+// then forgotten, so that the modification is reported once, even by
+// concurrent readers. It does nothing when debugging is off, and must be
+// called with the lock held.
+func checkSnapshot[T any](c *config, kind, name string, value *T, snapshot *atomic.Pointer[[]byte]) {
+	if !DebugIsOn {
+		return
+	}
+
+	s := snapshot.Load()
+	if s == nil || bytes.Equal(memoryOf(value), *s) || !snapshot.CompareAndSwap(s, nil) {
+		return
+	}
+
+	e := newEvent(report.KindMisuse, kind, name, kind+" "+name+" modified outside of its lock",
+		goroutine.ID(), debug.Stack())
+	c.emit(e)
+}
+
+// takeSnapshot copies the memory of the value into the snapshot, if
+// debugging is on. It must be called with the lock held for writing.
+func takeSnapshot[T any](value *T, snapshot *atomic.Pointer[[]byte]) {
+	if !DebugIsOn {
+		snapshot.Store(nil)
+		return
+	}
+
+	copied := bytes.Clone(memoryOf(value))
+	snapshot.Store(&copied)
+}
+
+// memoryOf returns the memory of the value, without copying it. The pointers
+// it contains are only compared, never followed.
+func memoryOf[T any](value *T) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(value)), unsafe.Sizeof(*value))
+}
@@ -0,0 +1,175 @@
+package sync
+
+import (
+	"math"
+	"testing"
+
+	"go.dedis.ch/debugtools/report"
+)
+
+type guardedState struct {
+	peers   []string
+	counter int
+}
+
+func misuseEvents(collector *report.Collector) []report.Event {
+	var events []report.Event
+
+	for _, e := range collector.Events() {
+		if e.Kind == report.KindMisuse {
+			events = append(events, e)
+		}
+	}
+
+	return events
+}
+
+func TestGuardedDebugOff(t *testing.T) {
+	DebugIsOn = false
+
+	var collector report.Collector
+	g := NewGuarded(guardedState{}, WithReporter(&collector))
+
+	var escaped *guardedState
+	g.With(func(s *guardedState) {
+		s.counter++
+		escaped = s
+	})
+
+	escaped.counter++
+
+	g.Read(func(s guardedState) {
+		if s.counter != 2 {
+			t.Fatalf("unexpected counter: %d", s.counter)
+		}
+	})
+
+	if events := misuseEvents(&collector); len(events) != 0 {
+		t.Fatalf("unexpected reports while debugging is off: %v", events)
+	}
+}
+
+func TestGuardedDebugOn(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+	ResetStats()
+
+	var collector report.Collector
+	g := NewGuarded(guardedState{}, WithName("guarded-state"), WithReporter(&collector))
+
+	var escaped *guardedState
+	g.With(func(s *guardedState) {
+		s.peers = append(s.peers, "a")
+		escaped = s
+	})
+	g.Read(func(guardedState) {})
+
+	if events := misuseEvents(&collector); len(events) != 0 {
+		t.Fatalf("unexpected reports: %v", events)
+	}
+
+	escaped.counter++
+
+	g.Read(func(guardedState) {})
+	g.With(func(*guardedState) {})
+
+	events := misuseEvents(&collector)
+	if len(events) != 1 || events[0].Message != "Guarded guarded-state modified outside of its lock" {
+		t.Fatalf("unexpected reports: %v", events)
+	}
+
+	stats, found := lockStatsOf("Mutex", "guarded-state")
+	if !found || stats.Hold.Count != 4 {
+		t.Fatalf("unexpected stats: %v", stats)
+	}
+}
+
+func TestRWGuardedDebugOn(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+
+	var collector report.Collector
+	g := NewRWGuarded(guardedState{}, WithName("rw-guarded-state"), WithReporter(&collector))
+
+	var escaped *guardedState
+	g.With(func(s *guardedState) { escaped = s })
+
+	escaped.counter = 42
+
+	g.Read(func(s guardedState) {
+		// a second reader while the first one holds the lock
+		done := make(chan struct{})
+		go g.Read(func(guardedState) { close(done) })
+		<-done
+
+		if s.counter != 42 {
+			t.Fatalf("unexpected counter: %d", s.counter)
+		}
+	})
+
+	events := misuseEvents(&collector)
+	if len(events) != 1 || events[0].Message != "RWGuarded rw-guarded-state modified outside of its lock" {
+		t.Fatalf("unexpected reports: %v", events)
+	}
+}
+
+type uncomparableState struct {
+	callback func()
+	ratio    float64
+}
+
+func TestGuardedUncomparable(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+
+	var collector report.Collector
+	g := NewGuarded(uncomparableState{}, WithReporter(&collector))
+
+	g.With(func(s *uncomparableState) {
+		s.callback = func() {}
+		s.ratio = math.NaN()
+	})
+	g.Read(func(uncomparableState) {})
+	g.With(func(*uncomparableState) {})
+	g.Read(func(uncomparableState) {})
+
+	if events := misuseEvents(&collector); len(events) != 0 {
+		t.Fatalf("unexpected reports: %v", events)
+	}
+}
+
+// paddedState has padding bytes between its fields.
+type paddedState struct {
+	flag  bool
+	count int64
+	small int8
+}
+
+func TestGuardedPadding(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+
+	var collector report.Collector
+	g := NewGuarded(paddedState{}, WithName("padded-state"), WithReporter(&collector))
+
+	var escaped *paddedState
+	g.With(func(s *paddedState) {
+		*s = paddedState{flag: true, count: 1, small: 2}
+		escaped = s
+	})
+	g.Read(func(paddedState) {})
+	g.With(func(*paddedState) {})
+
+	if events := misuseEvents(&collector); len(events) != 0 {
+		t.Fatalf("unexpected reports: %v", events)
+	}
+
+	escaped.small++
+
+	g.Read(func(paddedState) {})
+
+	events := misuseEvents(&collector)
+	if len(events) != 1 || events[0].Message != "Guarded padded-state modified outside of its lock" {
+		t.Fatalf("unexpected reports: %v", events)
+	}
+}
@@ -0,0 +1,38 @@
+package sync
+
+import (
+	"time"
+
+	"go.dedis.ch/debugtools/report"
+)
+
+// HistorySize is the default number of operations, such as lock and unlock,
+// each debug primitive remembers to add to its timeout reports. It can be
+// overridden per instance with WithHistory, and zero disables the history.
+var HistorySize = 32
+
+// packagePrefix is the prefix of the functions of the package, including the
+// closures of OnceFunc and the like, which are skipped when looking for the
+// caller of an operation.
+const packagePrefix = "go.dedis.ch/debugtools/sync."
+
+// newRecord describes the operation of the goroutine gid from the given call
+// site.
+func newRecord(gid uint64, action, site string) report.Record {
+	return report.Record{
+		Time:      time.Now(),
+		Goroutine: gid,
+		Action:    action,
+		Site:      site,
+	}
+}
+
+// releaseLabel describes the release of a lock held in the given mode, e.g.
+// "read unlock".
+func releaseLabel(mode string) string {
+	if mode == modeRLock {
+		return "read unlock"
+	}
+
+	return "unlock"
+}
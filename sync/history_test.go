@@ -0,0 +1,91 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.dedis.ch/debugtools/report"
+)
+
+// timeoutEvent returns the first timeout event collected.
+func timeoutEvent(t *testing.T, collector *report.Collector) report.Event {
+	for _, e := range collector.Events() {
+		if e.Kind == report.KindTimeout {
+			return e
+		}
+	}
+
+	t.Fatalf("no timeout reported: %v", collector.Events())
+
+	return report.Event{}
+}
+
+func TestHistoryDebugOff(t *testing.T) {
+	DebugIsOn = false
+
+	m := NewMutex()
+	m.Lock()
+	m.Unlock() //nolint:staticcheck // SA2001: empty critical section IGNORED !
+
+	if records := m.tracker.recent(); len(records) != 0 {
+		t.Fatalf("history recorded while debugging is off: %v", records)
+	}
+}
+
+func TestHistoryDebugOn(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+
+	var collector report.Collector
+	m := NewMutex(WithTimeout(10*time.Millisecond), WithReporter(&collector))
+
+	m.Lock()
+	m.Unlock() //nolint:staticcheck // SA2001: empty critical section IGNORED !
+
+	m.Lock()
+	time.AfterFunc(50*time.Millisecond, m.Unlock)
+	m.Lock()
+	m.Unlock()
+
+	e := timeoutEvent(t, &collector)
+
+	var actions []string
+	for _, r := range e.History {
+		actions = append(actions, r.Action)
+	}
+
+	expected := "wait lock, lock, unlock, wait lock, lock, wait lock"
+	if strings.Join(actions, ", ") != expected {
+		t.Fatalf("unexpected history: %v", e.History)
+	}
+	if !strings.Contains(e.String(), "history:") {
+		t.Fatalf("history not reported: %s", e)
+	}
+}
+
+func TestHistorySize(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+
+	var collector report.Collector
+	wg := NewWaitGroup(WithTimeout(10*time.Millisecond), WithReporter(&collector), WithHistory(2))
+
+	wg.Add(2)
+	wg.Done()
+	time.AfterFunc(50*time.Millisecond, wg.Done)
+	wg.Wait()
+
+	e := timeoutEvent(t, &collector)
+	if len(e.History) != 2 || e.History[0].Action != "add -1" || e.History[1].Action != "wait" {
+		t.Fatalf("unexpected history: %v", e.History)
+	}
+
+	m := NewMutex(WithHistory(0))
+	m.Lock()
+	m.Unlock() //nolint:staticcheck // SA2001: empty critical section IGNORED !
+
+	if records := m.tracker.recent(); len(records) != 0 {
+		t.Fatalf("history recorded while disabled: %v", records)
+	}
+}
@@ -0,0 +1,367 @@
+package sync
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.dedis.ch/debugtools/report"
+)
+
+// LockOrderSize is the maximum number of locks kept in the lock-order graph.
+// Beyond it, the locks used the least recently are forgotten, so that the
+// graph does not grow with short-lived locks. A cycle going through a
+// forgotten lock is not detected.
+var LockOrderSize = 10000
+
+// lastLockID is the last identifier given to a debug lock.
+var lastLockID uint64
+
+// lazyLockID returns the identifier stored in id. A new identifier is
+// allocated on first use so that the zero value of the locks stays usable.
+func lazyLockID(id *uint64) uint64 {
+	v := atomic.LoadUint64(id)
+	if v != 0 {
+		return v
+	}
+
+	atomic.CompareAndSwapUint64(id, 0, atomic.AddUint64(&lastLockID, 1))
+
+	return atomic.LoadUint64(id)
+}
+
+// lockName gives a printable name to the lock of the given kind and id.
+func lockName(kind string, id uint64) string {
+	return fmt.Sprintf("%s#%d", kind, id)
+}
+
+// heldLock is a lock held by a goroutine.
+type heldLock struct {
+	id    uint64
+	name  string
+	read  bool
+	rank  int
+	stack []byte
+}
+
+// orderEdge records that a lock has been acquired while holding another one.
+type orderEdge struct {
+	// gid is the goroutine which acquired both locks.
+	gid uint64
+	// heldStack is where the lock already held was acquired.
+	heldStack []byte
+	// stack is where the other lock was acquired.
+	stack []byte
+	// read tells whether both locks were acquired for reading.
+	read bool
+}
+
+// lockOrder is the global lock-order graph. An edge from A to B means that
+// a goroutine acquired B while holding A. A cycle in this graph is a
+// potential deadlock, even if it never happened during the run, unless all
+// its locks are acquired for reading, as readers do not block each other.
+type lockOrder struct {
+	mutex sync.Mutex
+	// held gives the locks held by each goroutine, in acquisition order.
+	held  map[uint64][]heldLock
+	names map[uint64]string
+	// used gives when each lock of the graph has been used the last time, as
+	// the value of clock.
+	used     map[uint64]uint64
+	clock    uint64
+	edges    map[uint64]map[uint64]orderEdge
+	reported map[[2]uint64]bool
+}
+
+// lockCycle is a cycle found in the lock-order graph, to be reported.
+type lockCycle struct {
+	event report.Event
+	// involved are the goroutines holding a lock of the cycle.
+	involved []uint64
+}
+
+var order = lockOrder{
+	held:     make(map[uint64][]heldLock),
+	names:    make(map[uint64]string),
+	used:     make(map[uint64]uint64),
+	edges:    make(map[uint64]map[uint64]orderEdge),
+	reported: make(map[[2]uint64]bool),
+}
+
+// acquire must be called by the goroutine gid before it blocks on the lock
+// id, for reading if read is set. It records the order between the locks
+// already held by gid and id, and reports a potential deadlock the first time
+// it closes a cycle. If gid already holds id, the corresponding held lock is
+// returned, giving preference to a write lock.
+func (o *lockOrder) acquire(gid, id uint64, name string, read bool, stack []byte) (heldLock, bool) {
+	self, found, cycles := o.record(gid, id, name, read, stack)
+
+	// the cycles are reported without holding the mutex, in case the reporter
+	// uses the debug locks
+	for _, c := range cycles {
+		(*config)(nil).emit(withDump(nil, c.event, c.involved...))
+	}
+
+	return self, found
+}
+
+func (o *lockOrder) record(gid, id uint64, name string, read bool, stack []byte) (self heldLock, found bool, cycles []lockCycle) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	_, known := o.used[id]
+	if !known {
+		// the graph only grows with new locks
+		defer o.trim()
+	}
+
+	o.names[id] = name
+	o.clock++
+	o.used[id] = o.clock
+
+	for _, h := range o.held[gid] {
+		if h.id == id {
+			if !found || !h.read {
+				self, found = h, true
+			}
+
+			continue
+		}
+
+		next := o.edges[h.id]
+		if next == nil {
+			next = make(map[uint64]orderEdge)
+			o.edges[h.id] = next
+		}
+
+		// an edge between read locks is replaced by one involving a write
+		// lock, which may close a cycle that was not one before
+		edge, known := next[id]
+		if known && (!edge.read || h.read && read) {
+			continue
+		}
+
+		edge = orderEdge{gid: gid, heldStack: h.stack, stack: stack, read: h.read && read}
+		next[id] = edge
+
+		path := o.path(id, h.id, !edge.read)
+		if path == nil {
+			continue
+		}
+
+		key := [2]uint64{h.id, id}
+		if id < h.id {
+			key = [2]uint64{id, h.id}
+		}
+
+		if o.reported[key] {
+			continue
+		}
+
+		o.reported[key] = true
+
+		cycle := append([]uint64{h.id}, path...)
+		cycles = append(cycles, lockCycle{
+			event:    o.cycleEvent(cycle, gid, stack),
+			involved: o.holders(cycle),
+		})
+	}
+
+	return self, found, cycles
+}
+
+// trim forgets the locks used the least recently, but not held, once the
+// graph has more than LockOrderSize locks, down to three quarters of it so
+// that trimming is done in batches rather than for every new lock. It must be
+// called with the mutex locked.
+func (o *lockOrder) trim() {
+	if len(o.used) <= LockOrderSize {
+		return
+	}
+
+	held := make(map[uint64]bool)
+	for _, locks := range o.held {
+		for _, h := range locks {
+			held[h.id] = true
+		}
+	}
+
+	ids := make([]uint64, 0, len(o.used))
+	for id := range o.used {
+		if !held[id] {
+			ids = append(ids, id)
+		}
+	}
+
+	slices.SortFunc(ids, func(a, b uint64) int { return cmp.Compare(o.used[a], o.used[b]) })
+
+	n := min(len(ids), len(o.used)-LockOrderSize*3/4)
+	forgotten := make(map[uint64]bool, n)
+
+	for _, id := range ids[:n] {
+		forgotten[id] = true
+		delete(o.names, id)
+		delete(o.used, id)
+		delete(o.edges, id)
+	}
+
+	for _, next := range o.edges {
+		for id := range next {
+			if forgotten[id] {
+				delete(next, id)
+			}
+		}
+	}
+
+	for key := range o.reported {
+		if forgotten[key[0]] || forgotten[key[1]] {
+			delete(o.reported, key)
+		}
+	}
+}
+
+// acquired must be called by the goroutine gid once it holds the lock.
+func (o *lockOrder) acquired(gid uint64, lock heldLock) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.held[gid] = append(o.held[gid], lock)
+}
+
+// released must be called when the lock id, held for reading if read is
+// set, is released by the goroutine gid. Since a lock can be released by
+// another goroutine than the one which acquired it, any holder is considered
+// if gid does not hold it.
+func (o *lockOrder) released(gid, id uint64, read bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.remove(gid, id, read) {
+		return
+	}
+
+	for holder := range o.held {
+		if o.remove(holder, id, read) {
+			return
+		}
+	}
+}
+
+func (o *lockOrder) remove(gid, id uint64, read bool) bool {
+	held := o.held[gid]
+
+	for i := len(held) - 1; i >= 0; i-- {
+		if held[i].id != id || held[i].read != read {
+			continue
+		}
+
+		held = append(held[:i], held[i+1:]...)
+		if len(held) == 0 {
+			delete(o.held, gid)
+		} else {
+			o.held[gid] = held
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// path returns the locks on a path going from one lock to the other in the
+// lock-order graph, both included, or nil if there is none. Unless write is
+// set, the path must have an edge involving a write lock.
+func (o *lockOrder) path(from, to uint64, write bool) []uint64 {
+	// a step is a lock reached by a path which involves a write lock or not
+	type step struct {
+		id    uint64
+		write bool
+	}
+
+	start := step{id: from, write: write}
+	previous := map[step]step{start: start}
+	queue := []step{start}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if current.id == to && current.write {
+			path := []uint64{to}
+			for current != start {
+				current = previous[current]
+				path = append([]uint64{current.id}, path...)
+			}
+
+			return path
+		}
+
+		// a path through either lock again would go through another cycle
+		if current.id == to {
+			continue
+		}
+
+		for id, edge := range o.edges[current.id] {
+			next := step{id: id, write: current.write || !edge.read}
+
+			_, seen := previous[next]
+			if !seen && id != from {
+				previous[next] = current
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	return nil
+}
+
+// holders returns the goroutines holding any of the given locks. It must be
+// called with the mutex locked.
+func (o *lockOrder) holders(ids []uint64) []uint64 {
+	var gids []uint64
+
+	for gid, held := range o.held {
+		for _, h := range held {
+			if slices.Contains(ids, h.id) {
+				gids = append(gids, gid)
+				break
+			}
+		}
+	}
+
+	return gids
+}
+
+// cycleEvent creates the event reporting a cycle in the lock-order graph,
+// given as the list of its locks with the first one repeated at the end. The
+// cycle has been closed by the goroutine gid from the given stack.
+func (o *lockOrder) cycleEvent(cycle []uint64, gid uint64, stack []byte) report.Event {
+	names := make([]string, len(cycle))
+	for i, id := range cycle {
+		names[i] = o.names[id]
+	}
+
+	msg := fmt.Sprintf("potential deadlock: lock order cycle %s", strings.Join(names, " -> "))
+	e := newEvent(report.KindLockOrder, "lock", names[0], msg, gid, stack)
+
+	for i := 0; i+1 < len(cycle); i++ {
+		edge := o.edges[cycle[i]][cycle[i+1]]
+
+		e.Related = append(e.Related,
+			report.Goroutine{
+				ID:    edge.gid,
+				Role:  fmt.Sprintf("%s acquired while holding %s by", names[i+1], names[i]),
+				Stack: string(edge.stack),
+			},
+			report.Goroutine{
+				ID:    edge.gid,
+				Role:  fmt.Sprintf("%s acquired by", names[i]),
+				Stack: string(edge.heldStack),
+			})
+	}
+
+	return e
+}
@@ -0,0 +1,231 @@
+package sync
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// logBuffer is a buffer which can be written by the timers while the tests
+// are reading it.
+type logBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buffer.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buffer.String()
+}
+
+func (b *logBuffer) Len() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buffer.Len()
+}
+
+func (b *logBuffer) Reset() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.buffer.Reset()
+}
+
+// setupLogger is a helper function to use a testable logger. The original
+// logger is restored at the end of the test.
+func setupLogger(t *testing.T) *logBuffer {
+	original := Logger
+	t.Cleanup(func() { Logger = original })
+
+	b := new(logBuffer)
+	Logger = zerolog.New(b).Level(zerolog.WarnLevel)
+
+	return b
+}
+
+func TestLockOrderInversion(t *testing.T) {
+	DebugIsOn = true
+	l := setupLogger(t)
+
+	var a Mutex
+	var b RWMutex
+
+	a.Lock()
+	b.Lock()
+	b.Unlock()
+	a.Unlock()
+
+	if strings.Contains(l.String(), "potential deadlock") {
+		t.Fatalf("unexpected report: %s", l.String())
+	}
+
+	b.RLock()
+	a.Lock()
+	a.Unlock()
+	b.RUnlock()
+
+	out := l.String()
+	if !strings.Contains(out, "potential deadlock") {
+		t.Fatalf("lock order inversion not reported: %s", out)
+	}
+	if !strings.Contains(out, a.name()) || !strings.Contains(out, b.name()) {
+		t.Fatalf("report does not name both locks: %s", out)
+	}
+
+	l.Reset()
+
+	b.RLock()
+	a.Lock()
+	a.Unlock()
+	b.RUnlock()
+
+	if l.Len() != 0 {
+		t.Fatalf("inversion reported twice: %s", l.String())
+	}
+}
+
+func TestLockOrderReadLocks(t *testing.T) {
+	DebugIsOn = true
+	l := setupLogger(t)
+
+	var a, b RWMutex
+
+	a.RLock()
+	b.RLock()
+	b.RUnlock()
+	a.RUnlock()
+
+	b.RLock()
+	a.RLock()
+	a.RUnlock()
+	b.RUnlock()
+
+	if l.Len() != 0 {
+		t.Fatalf("inversion of read locks reported: %s", l.String())
+	}
+
+	b.RLock()
+	a.Lock()
+	a.Unlock()
+	b.RUnlock()
+
+	expected := strings.Join([]string{b.name(), a.name(), b.name()}, " -> ")
+	if !strings.Contains(l.String(), expected) {
+		t.Fatalf("inversion with a write lock not reported: %s", l.String())
+	}
+}
+
+func TestLockOrderCycle(t *testing.T) {
+	DebugIsOn = true
+	l := setupLogger(t)
+
+	var a, b, c Mutex
+
+	lockPair := func(first, second *Mutex) {
+		first.Lock()
+		second.Lock()
+		second.Unlock()
+		first.Unlock()
+	}
+
+	lockPair(&a, &b)
+	lockPair(&b, &c)
+
+	if l.Len() != 0 {
+		t.Fatalf("unexpected report: %s", l.String())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		lockPair(&c, &a)
+		close(done)
+	}()
+	<-done
+
+	expected := strings.Join([]string{c.name(), a.name(), b.name(), c.name()}, " -> ")
+	if !strings.Contains(l.String(), expected) {
+		t.Fatalf("lock order cycle not reported: %s", l.String())
+	}
+}
+
+func TestLockOrderReleasedByOtherGoroutine(t *testing.T) {
+	DebugIsOn = true
+	l := setupLogger(t)
+
+	var a, b Mutex
+
+	a.Lock()
+	done := make(chan struct{})
+	go func() {
+		a.Unlock()
+		close(done)
+	}()
+	<-done
+
+	b.Lock()
+	a.Lock()
+	a.Unlock()
+	b.Unlock()
+
+	if l.Len() != 0 {
+		t.Fatalf("unexpected report: %s", l.String())
+	}
+}
+
+func TestLockOrderSize(t *testing.T) {
+	DebugIsOn = true
+	l := setupLogger(t)
+
+	original := LockOrderSize
+	LockOrderSize = 100
+	defer func() { LockOrderSize = original }()
+
+	var longLived Mutex
+
+	for i := 0; i < 1000; i++ {
+		var shortLived Mutex
+
+		longLived.Lock()
+		shortLived.Lock()
+		shortLived.Unlock()
+		longLived.Unlock()
+	}
+
+	order.mutex.Lock()
+	names, edges := len(order.names), len(order.edges[longLived.lockID()])
+	order.mutex.Unlock()
+
+	if names > LockOrderSize || edges > LockOrderSize {
+		t.Fatalf("lock-order graph not trimmed: %d locks, %d edges", names, edges)
+	}
+
+	// the long-lived lock is still in the graph
+	var other Mutex
+
+	longLived.Lock()
+	other.Lock()
+	other.Unlock()
+	longLived.Unlock()
+
+	other.Lock()
+	longLived.Lock()
+	longLived.Unlock()
+	other.Unlock()
+
+	if !strings.Contains(l.String(), "potential deadlock") {
+		t.Fatalf("lock order inversion not reported: %s", l.String())
+	}
+}
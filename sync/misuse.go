@@ -0,0 +1,46 @@
+package sync
+
+import (
+	"go.dedis.ch/debugtools/report"
+)
+
+// OnMisuse is the action taken in the offending goroutine when a debug
+// primitive detects a misuse which is certain to deadlock, once the event has
+// been reported.
+var OnMisuse = report.ActionLog
+
+// withHolder adds to the event of a misuse by the goroutine gid the lock it
+// already holds, as described by held.
+func withHolder(e report.Event, gid uint64, held heldLock) report.Event {
+	role := modeLock + " already held by"
+	if held.read {
+		role = modeRLock + " already held by"
+	}
+
+	e.Related = append(e.Related, report.Goroutine{
+		ID:    gid,
+		Role:  role,
+		Stack: string(held.stack),
+	})
+
+	return e
+}
+
+// raiseMisuse emits the event of a misuse certain to deadlock, and takes the
+// OnMisuse action.
+func raiseMisuse(c *config, e report.Event) {
+	action := OnMisuse
+	c.emit(e)
+	takeAction(action, e)
+}
+
+// takeAction panics with the event e or exits the process, as given by the
+// action. It does nothing for ActionLog.
+func takeAction(action report.Action, e report.Event) {
+	switch action {
+	case report.ActionExit:
+		report.Exit(e)
+	case report.ActionPanic:
+		panic(e.String())
+	}
+}
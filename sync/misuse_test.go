@@ -0,0 +1,139 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"go.dedis.ch/debugtools/report"
+)
+
+// expectMisusePanic is a helper function checking that f panics with a
+// misuse report containing the given message and the stacks of both calls.
+func expectMisusePanic(t *testing.T, msg string, f func()) {
+	t.Helper()
+
+	original := OnMisuse
+	OnMisuse = report.ActionPanic
+	defer func() { OnMisuse = original }()
+
+	defer func() {
+		t.Helper()
+
+		report := fmt.Sprint(recover())
+		if !strings.Contains(report, msg) {
+			t.Fatalf("unexpected panic: %s", report)
+		}
+		if strings.Count(report, "TestSelfDeadlock") < 2 {
+			t.Fatalf("stacks not reported: %s", report)
+		}
+	}()
+
+	f()
+}
+
+func TestSelfDeadlockRecursiveLock(t *testing.T) {
+	DebugIsOn = true
+
+	var m Mutex
+	m.Lock()
+	defer m.Unlock()
+
+	expectMisusePanic(t, "self-deadlock: recursive Mutex.Lock", m.Lock)
+}
+
+func TestSelfDeadlockLockUpgrade(t *testing.T) {
+	DebugIsOn = true
+
+	var m RWMutex
+	m.RLock()
+	defer m.RUnlock()
+
+	expectMisusePanic(t, "self-deadlock: RWMutex.Lock while holding a read lock", m.Lock)
+}
+
+func TestSelfDeadlockRLockWhileLocked(t *testing.T) {
+	DebugIsOn = true
+
+	var m RWMutex
+	m.Lock()
+	defer m.Unlock()
+
+	expectMisusePanic(t, "self-deadlock: RWMutex.RLock while holding the lock", m.RLock)
+	expectMisusePanic(t, "self-deadlock: recursive RWMutex.Lock", m.Lock)
+}
+
+func TestSelfDeadlockLogged(t *testing.T) {
+	DebugIsOn = true
+	l := setupLogger(t)
+
+	var m Mutex
+
+	locked := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		m.Lock()
+		close(locked)
+		m.Lock()
+		m.Unlock()
+		close(done)
+	}()
+	<-locked
+
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(l.String(), "self-deadlock") && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if !strings.Contains(l.String(), "self-deadlock: recursive Mutex.Lock") {
+		t.Fatalf("self-deadlock not reported: %s", l.String())
+	}
+
+	// release the lock on behalf of the deadlocked goroutine
+	m.Unlock()
+	<-done
+}
+
+func TestRecursiveRLockWarning(t *testing.T) {
+	DebugIsOn = true
+	l := setupLogger(t)
+
+	original := OnMisuse
+	OnMisuse = report.ActionPanic
+	defer func() { OnMisuse = original }()
+
+	var m RWMutex
+	m.RLock()
+	m.RLock()
+	m.RUnlock()
+	m.RUnlock()
+
+	if !strings.Contains(l.String(), "recursive RWMutex.RLock deadlocks if a writer is waiting") {
+		t.Fatalf("recursive read lock not reported: %s", l.String())
+	}
+}
+
+func TestSelfDeadlockRecursiveRLockWithWriter(t *testing.T) {
+	DebugIsOn = true
+
+	var m RWMutex
+	m.RLock()
+
+	done := make(chan struct{})
+	go func() {
+		m.Lock()
+		m.Unlock() //nolint:staticcheck // SA2001: empty critical section IGNORED !
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for len(m.tracker.waiting(modeLock)) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	expectMisusePanic(t, "self-deadlock: recursive RWMutex.RLock with a pending writer", m.RLock)
+
+	m.RUnlock()
+	<-done
+}
@@ -1,17 +1,99 @@
-// Package sync defines the logger.
+// Package sync provides the primitives of the standard sync package, with
+// tools to debug their use: timeouts on the waits, detection of potential
+// deadlocks and misuses, and statistics on the locks and pools.
 //
-// sync is using a global logger with some default parameters.
-// It is disabled by default and the level can be increased using
-// an environment variable:
+// # Enabling
+//
+// The debugging is disabled by default, and the primitives then work
+// seemingly like the ones of the standard sync package. To enable it, use the
+// following environment variable, e.g:
+//
+//	SYNCON=true
+//
+// sync is using a global logger with some default parameters. It is disabled
+// by default and the level can be increased using an environment variable:
 //
 //	SYNCLOG=trace
 //	SYNCLOG=info
 //
-// sync main feature is disabled by default and thus works seemingly
-// like the original sync package from the standard. To enable the debugging
-// feature, use the following environment variable, e.g:
+// The primitives can be given a name, a timeout, a logger and a reporter of
+// their own when created with NewMutex, NewRWMutex, NewWaitGroup, NewCond,
+// NewOnce, NewPool or NewTypedPool. The global Timeout, Logger and Reporter
+// are used otherwise.
 //
-//	SYNCON=true
+// # Lock checks
+//
+// When debugging is on, the order in which each goroutine acquires the
+// mutexes is recorded in a global lock-order graph. The first time an
+// acquisition closes a cycle in that graph, a potential deadlock is logged
+// with the acquisition stacks, even if it never actually deadlocked. A cycle
+// of read locks only is not reported. The graph keeps the LockOrderSize locks
+// used the most recently.
+//
+// Locks created with WithRank are also checked against a declared hierarchy:
+// acquiring a lock while holding one of a higher rank is reported, and takes
+// the OnRankViolation action.
+//
+// The locks can also assert that they are held, or not, by the calling
+// goroutine, and HeldLocks lists the locks it holds. A failed assertion is
+// reported, and takes the OnAssertion action.
+//
+// # Primitives
+//
+// Guarded and RWGuarded bundle a value with its lock, and report the value
+// modified outside of the lock through a reference which escaped an access.
+//
+// A Cond waiting for too long is reported with its number of waiters and
+// its last Signal or Broadcast.
+//
+// A Once reports a recursive call to Do, a function running for too long with
+// the goroutines blocked on it, and a panic of the function. So do the
+// functions returned by OnceFunc, OnceValue and OnceValues.
+//
+// The objects given by a Pool and not put back yet are counted per call site
+// of Get, available with Pools, and the ones implementing Poisoner are
+// poisoned when put back instead of being reused. TypedPool is a Pool of
+// objects of a given type.
+//
+// # Reports
+//
+// Timeouts, lock-order cycles and misuses are emitted as events of
+// go.dedis.ch/debugtools/report, which are logged unless a Reporter is set.
+//
+// In CI, a goroutine waiting for too long can panic instead, or the process
+// can exit with report.ExitCode after dumping all the goroutines:
+//
+//	SYNCACTION=panic
+//	SYNCACTION=exit
+//
+// The misuses certain to deadlock take the OnMisuse action the same way.
+//
+// All the goroutines can also be dumped to a file in a given directory on a
+// timeout or a lock-order cycle, while the report only keeps the stacks of
+// the goroutines holding or waiting on the primitives involved:
+//
+//	SYNCDUMP=/tmp/goroutines
+//
+// Each primitive remembers its last HistorySize operations, such as who
+// locked and unlocked it, when and from where, which are added to its timeout
+// reports.
+//
+// # Statistics
+//
+// The counters of the wait groups and of the pools, which only cost an atomic
+// addition, are always kept up to date. The times, call sites and events of
+// the primitives are only recorded when debugging is on.
+//
+// The wait and hold times of the mutexes are recorded in histograms per name
+// and per call site, available with Stats.
+//
+// The goroutines holding or waiting on the primitives are also added to the
+// debugsync.held and debugsync.waiting pprof profiles, and to runtime/trace
+// regions such as "Mutex peers wait lock" and tasks such as "Mutex peers hold
+// lock" when tracing.
+//
+// The mutexes and wait groups in use are also listed in the registry of
+// go.dedis.ch/debugtools/registry, when it is enabled.
 package sync
 
 import (
@@ -20,6 +102,7 @@ import (
 	"time"
 
 	"github.com/rs/zerolog"
+	"go.dedis.ch/debugtools/report"
 )
 
 // EnvLogLevel is the name of the environment variable to change the logging
@@ -29,6 +112,13 @@ const EnvLogLevel = "SYNCLOG"
 // EnvDebugSwitch is the name of the environment variable to allow debugging.
 const EnvDebugSwitch = "SYNCON"
 
+// EnvTimeoutAction is the name of the environment variable to set OnTimeout,
+// to either log, panic or exit.
+const EnvTimeoutAction = "SYNCACTION"
+
+// EnvDumpDir is the name of the environment variable to set DumpDir.
+const EnvDumpDir = "SYNCDUMP"
+
 const defaultLevel = zerolog.NoLevel
 
 func init() {
@@ -57,6 +147,14 @@ func init() {
 	}
 
 	Logger = Logger.Level(level)
+
+	action, err := report.ParseAction(os.Getenv(EnvTimeoutAction))
+	if err != nil {
+		Logger.Warn().Err(err).Msg("ignoring " + EnvTimeoutAction)
+	}
+
+	OnTimeout = action
+	DumpDir = os.Getenv(EnvDumpDir)
 }
 
 var logout = zerolog.ConsoleWriter{
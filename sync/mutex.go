@@ -1,8 +1,13 @@
 package sync
 
 import (
+	"context"
 	"runtime/debug"
 	"sync"
+	"time"
+
+	"go.dedis.ch/debugtools/internal/goroutine"
+	"go.dedis.ch/debugtools/report"
 )
 
 // A Mutex is a mutual exclusion lock.
@@ -19,6 +24,15 @@ import (
 type Mutex struct {
 	mutex     sync.Mutex
 	unlocking chan struct{}
+	id        uint64
+	tracker   tracker
+	cfg       *config
+}
+
+// NewMutex creates a Mutex configured with the given options.
+// A Mutex created this way can be copied before its first use.
+func NewMutex(opts ...Option) *Mutex {
+	return &Mutex{cfg: newConfig(opts)}
 }
 
 // Lock locks m.
@@ -26,15 +40,72 @@ type Mutex struct {
 // blocks until the mutex is available.
 func (m *Mutex) Lock() {
 	if DebugIsOn {
-		Logger.Debug().Msg("Locking")
-		locking := startLockTimer("RWMutex timed out when acquiring lock", debug.Stack())
+		_ = m.debugLock(context.Background())
+	} else {
 		m.mutex.Lock()
-		close(locking)
+	}
+}
 
-		m.unlocking = startLockTimer("RWMutex timed out before releasing lock", debug.Stack())
+// LockContext locks m, unless ctx is done before the mutex is available.
+// In that case, a *LockError wrapping the error of the context is returned.
+func (m *Mutex) LockContext(ctx context.Context) error {
+	if DebugIsOn {
+		return m.debugLock(ctx)
+	}
+
+	err := acquireContext(ctx, m.mutex.TryLock, m.mutex.Lock, m.mutex.Unlock)
+	if err != nil {
+		return &LockError{Name: m.name(), Err: err}
+	}
+
+	return nil
+}
+
+// LockTimeout locks m, unless the mutex is not available after the given
+// timeout. In that case, a *LockError is returned.
+func (m *Mutex) LockTimeout(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return m.LockContext(ctx)
+}
+
+func (m *Mutex) debugLock(ctx context.Context) error {
+	m.cfg.getLogger().Debug().Str("name", m.name()).Msg("Locking")
+	gid, stack := goroutine.ID(), debug.Stack()
+	held, self := order.acquire(gid, m.lockID(), m.name(), false, stack)
+	if self {
+		e := m.event(report.KindSelfDeadlock, "self-deadlock: recursive Mutex.Lock", gid, stack)
+		raiseMisuse(m.cfg, withHolder(e, gid, held))
 	} else {
-		m.mutex.Lock()
+		checkRank(m.cfg, "Mutex", gid, m.heldLock(stack))
 	}
+	// the wait starts before the goroutine is seen waiting
+	start := time.Now()
+	m.track().wait(gid, modeLock, stack)
+
+	e := m.event(report.KindTimeout, "Mutex timed out when acquiring lock", gid, stack)
+	ctx, abort := waitContext(ctx)
+	if abort != nil {
+		defer abort(nil)
+	}
+
+	locking := startWaitTimer(m.cfg, e, &m.tracker, abort)
+	err := acquireContext(ctx, m.mutex.TryLock, m.mutex.Lock, m.mutex.Unlock)
+	close(locking)
+	recordWait(m.cfg, "Mutex", modeLock, stack, time.Since(start))
+
+	if err != nil {
+		m.tracker.stopWaiting(gid, modeLock)
+		panicOnTimeout(ctx)
+		return &LockError{Name: m.name(), Err: err, Holders: m.tracker.String()}
+	}
+
+	order.acquired(gid, m.heldLock(stack))
+	m.tracker.hold(gid, modeLock, stack, nil)
+	m.unlocking = m.startHoldTimer(gid, stack)
+
+	return nil
 }
 
 // TryLock tries to lock m and reports whether it succeeded.
@@ -46,7 +117,10 @@ func (m *Mutex) TryLock() bool {
 	locked := m.mutex.TryLock()
 
 	if DebugIsOn && locked {
-		m.unlocking = startLockTimer("RWMutex timed out before releasing lock", debug.Stack())
+		gid, stack := goroutine.ID(), debug.Stack()
+		order.acquired(gid, m.heldLock(stack))
+		m.track().hold(gid, modeLock, stack, nil)
+		m.unlocking = m.startHoldTimer(gid, stack)
 	}
 
 	return locked
@@ -62,6 +136,59 @@ func (m *Mutex) Unlock() {
 	if DebugIsOn && m.unlocking != nil {
 		close(m.unlocking)
 		m.unlocking = nil
+
+		gid := goroutine.ID()
+		order.released(gid, m.lockID(), false)
+
+		held, found := m.tracker.release(gid, modeLock)
+		if found {
+			recordHold(m.cfg, "Mutex", held)
+		}
 	}
 	m.mutex.Unlock()
 }
+
+// AssertHeld checks that m is held by the calling goroutine, if debugging is
+// on. Otherwise, it does nothing.
+func (m *Mutex) AssertHeld() {
+	if DebugIsOn {
+		assertHeld(m.cfg, "Mutex", m.name(), m.lockID(), false)
+	}
+}
+
+// AssertNotHeld checks that m is not held by the calling goroutine, if
+// debugging is on. Otherwise, it does nothing.
+func (m *Mutex) AssertNotHeld() {
+	if DebugIsOn {
+		assertNotHeld(m.cfg, "Mutex", m.name(), m.lockID())
+	}
+}
+
+func (m *Mutex) lockID() uint64 {
+	return lazyLockID(&m.id)
+}
+
+// heldLock describes m held by a goroutine from the given stack.
+func (m *Mutex) heldLock(stack []byte) heldLock {
+	return heldLock{id: m.lockID(), name: m.name(), rank: m.cfg.getRank(), stack: stack}
+}
+
+func (m *Mutex) name() string {
+	return m.cfg.nameOr(lockName("Mutex", m.lockID()))
+}
+
+func (m *Mutex) event(kind report.Kind, msg string, gid uint64, stack []byte) report.Event {
+	return newEvent(kind, "Mutex", m.name(), msg, gid, stack)
+}
+
+// startHoldTimer starts the timer reporting that the goroutine gid holds m
+// for too long.
+func (m *Mutex) startHoldTimer(gid uint64, stack []byte) chan struct{} {
+	e := m.event(report.KindHoldTimeout, "Mutex timed out before releasing lock", gid, stack)
+	return startLockTimer(m.cfg, e, &m.tracker)
+}
+
+func (m *Mutex) track() *tracker {
+	m.tracker.identify("Mutex", m.name(), nil, m.cfg.getHistorySize())
+	return &m.tracker
+}
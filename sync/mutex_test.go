@@ -176,8 +176,13 @@ func TestMutexMisuseDebugOn(t *testing.T) {
 func mutexFairness(t *testing.T) {
 	var mu Mutex
 	stop := make(chan bool)
-	defer close(stop)
+	stopped := make(chan bool)
+	defer func() {
+		close(stop)
+		<-stopped
+	}()
 	go func() {
+		defer close(stopped)
 		for {
 			mu.Lock()
 			time.Sleep(100 * time.Microsecond)
@@ -1,10 +1,156 @@
 package sync
 
 import (
-	_sync "sync"
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"go.dedis.ch/debugtools/internal/goroutine"
+	"go.dedis.ch/debugtools/report"
 )
 
 // Once is an object that will perform exactly one action.
+//
+// When debugging is on, a call to Do from the function of the same Once is
+// reported at once as a self-deadlock, a function running for longer than
+// the timeout is reported with the goroutines blocked on it, and a panic of
+// the function is reported before being propagated.
+//
+// A Once must not be copied after first use.
 type Once struct {
-	_sync.Once
+	done  uint32
+	mutex sync.Mutex
+	// running is the goroutine running the function, if any.
+	running uint64
+	id      uint64
+	tracker tracker
+	cfg     *config
+}
+
+// NewOnce creates a Once configured with the given options.
+// A Once created this way can be copied before its first use.
+func NewOnce(opts ...Option) *Once {
+	return &Once{cfg: newConfig(opts)}
+}
+
+// Do calls the function f if and only if Do is being called for the
+// first time for this instance of Once. In other words, given
+//
+//	var once Once
+//
+// if once.Do(f) is called multiple times, only the first call will invoke f,
+// even if f has a different value in each invocation. A new instance of
+// Once is required for each function to execute.
+//
+// Because no call to Do returns until the one call to f returns, if f causes
+// Do to be called, it will deadlock.
+//
+// If f panics, Do considers it to have returned; future calls of Do return
+// without calling f.
+func (o *Once) Do(f func()) {
+	if atomic.LoadUint32(&o.done) != 0 {
+		return
+	}
+
+	if DebugIsOn {
+		o.debugDo(f)
+	} else {
+		o.doSlow(f)
+	}
+}
+
+func (o *Once) doSlow(f func()) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.done == 0 {
+		defer atomic.StoreUint32(&o.done, 1)
+		f()
+	}
+}
+
+func (o *Once) debugDo(f func()) {
+	gid, stack := goroutine.ID(), debug.Stack()
+	if atomic.LoadUint64(&o.running) == gid {
+		e := o.event(report.KindSelfDeadlock, "self-deadlock: recursive Once.Do", gid, stack)
+		e.Related = o.tracker.holding()
+		raiseMisuse(o.cfg, e)
+	}
+	o.track().wait(gid, modeWait, stack)
+
+	ctx, abort := waitContext(context.Background())
+	if abort != nil {
+		defer abort(nil)
+	}
+
+	e := o.event(report.KindTimeout, "Once timed out waiting for its function", gid, stack)
+	waiting := startWaitTimer(o.cfg, e, &o.tracker, abort)
+	err := acquireContext(ctx, o.mutex.TryLock, o.mutex.Lock, o.mutex.Unlock)
+	close(waiting)
+
+	if err != nil {
+		o.tracker.stopWaiting(gid, modeWait)
+		panicOnTimeout(ctx)
+		return
+	}
+	defer o.mutex.Unlock()
+
+	if o.done != 0 {
+		o.tracker.stopWaiting(gid, modeWait)
+		return
+	}
+
+	o.run(gid, stack, f)
+}
+
+// run calls f in the goroutine gid, from the given stack, while it is
+// tracked as holding o.
+func (o *Once) run(gid uint64, stack []byte, f func()) {
+	e := o.event(report.KindHoldTimeout, "Once timed out running its function", gid, stack)
+	running := startLockTimer(o.cfg, e, &o.tracker)
+	o.tracker.stopWaiting(gid, modeWait)
+	o.tracker.hold(gid, modeRun, stack, running)
+	atomic.StoreUint64(&o.running, gid)
+
+	defer func() {
+		atomic.StoreUint64(&o.running, 0)
+		atomic.StoreUint32(&o.done, 1)
+		close(running)
+		o.tracker.release(gid, modeRun)
+
+		r := recover()
+		if r != nil {
+			msg := fmt.Sprintf("Once function panicked: %v", r)
+			o.cfg.emit(o.event(report.KindPanic, msg, gid, debug.Stack()))
+			panic(r)
+		}
+	}()
+
+	f()
+}
+
+func (o *Once) name() string {
+	return o.cfg.nameOr(lockName("Once", lazyLockID(&o.id)))
+}
+
+func (o *Once) event(kind report.Kind, msg string, gid uint64, stack []byte) report.Event {
+	return newEvent(kind, "Once", o.name(), msg, gid, stack)
+}
+
+func (o *Once) track() *tracker {
+	o.tracker.identify("Once", o.name(), o.status, o.cfg.getHistorySize())
+	return &o.tracker
+}
+
+func (o *Once) status() string {
+	switch {
+	case atomic.LoadUint64(&o.running) != 0:
+		return "running"
+	case atomic.LoadUint32(&o.done) != 0:
+		return "done"
+	default:
+		return "not done"
+	}
 }
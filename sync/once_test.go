@@ -0,0 +1,151 @@
+// This file is adapted from the GO sync package.
+// It originally contains the following license:
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sync
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.dedis.ch/debugtools/report"
+)
+
+type one int
+
+func (o *one) Increment() {
+	*o++
+}
+
+func run(t *testing.T, once *Once, o *one, c chan bool) {
+	once.Do(func() { o.Increment() })
+	if v := *o; v != 1 {
+		t.Errorf("once failed inside run: %d is not 1", v)
+	}
+	c <- true
+}
+
+func testOnce(t *testing.T) {
+	o := new(one)
+	once := new(Once)
+	c := make(chan bool)
+	const N = 10
+	for i := 0; i < N; i++ {
+		go run(t, once, o, c)
+	}
+	for i := 0; i < N; i++ {
+		<-c
+	}
+	if *o != 1 {
+		t.Errorf("once failed outside run: %d is not 1", *o)
+	}
+}
+
+func TestOnceDebugOff(t *testing.T) {
+	DebugIsOn = false
+	testOnce(t)
+}
+
+func TestOnceDebugOn(t *testing.T) {
+	DebugIsOn = true
+	testOnce(t)
+}
+
+func testOncePanic(t *testing.T) {
+	var once Once
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatalf("Once.Do did not panic")
+			}
+		}()
+		once.Do(func() {
+			panic("failed")
+		})
+	}()
+
+	once.Do(func() {
+		t.Fatalf("Once.Do called twice")
+	})
+}
+
+func TestOncePanicDebugOff(t *testing.T) {
+	DebugIsOn = false
+	testOncePanic(t)
+}
+
+func TestOncePanicDebugOn(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+	testOncePanic(t)
+}
+
+func TestOncePanicReported(t *testing.T) {
+	DebugIsOn = true
+
+	var collector report.Collector
+	once := NewOnce(WithName("config"), WithReporter(&collector))
+
+	func() {
+		defer func() { _ = recover() }()
+		once.Do(func() { panic("failed") })
+	}()
+
+	events := collector.Events()
+	if len(events) != 1 || events[0].Kind != report.KindPanic ||
+		events[0].Message != "Once function panicked: failed" ||
+		!strings.Contains(events[0].Stack, "TestOncePanicReported") {
+		t.Fatalf("unexpected reports: %v", events)
+	}
+}
+
+func TestSelfDeadlockRecursiveOnce(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+
+	var once Once
+
+	expectMisusePanic(t, "self-deadlock: recursive Once.Do", func() {
+		once.Do(func() { once.Do(func() {}) })
+	})
+}
+
+func TestOnceTimeout(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+
+	var collector report.Collector
+	once := NewOnce(WithName("slow"), WithTimeout(10*time.Millisecond), WithReporter(&collector))
+
+	started := make(chan struct{})
+	go once.Do(func() {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+	})
+
+	<-started
+	once.Do(func() { t.Fatal("Once.Do called twice") })
+
+	var waited, ran report.Event
+	for _, e := range collector.Events() {
+		switch e.Kind {
+		case report.KindTimeout:
+			waited = e
+		case report.KindHoldTimeout:
+			ran = e
+		}
+	}
+
+	runner := "created by go.dedis.ch/debugtools/sync.TestOnceTimeout"
+	if waited.Message != "Once timed out waiting for its function (running, 1 waiter(s))" ||
+		len(waited.Related) != 1 || !strings.Contains(waited.Related[0].Stack, runner) {
+		t.Fatalf("unexpected report of the waiter: %v", waited)
+	}
+	if ran.Message != "Once timed out running its function" ||
+		len(ran.Related) != 1 || !strings.Contains(ran.Related[0].Stack, "TestOnceTimeout(") {
+		t.Fatalf("unexpected report of the function: %v", ran)
+	}
+}
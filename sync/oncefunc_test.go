@@ -0,0 +1,125 @@
+// This file is adapted from the GO sync package.
+// It originally contains the following license:
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"go.dedis.ch/debugtools/report"
+)
+
+func testOnceFunc(t *testing.T) {
+	calls := 0
+	f := OnceFunc(func() { calls++ })
+	allocs := testing.AllocsPerRun(10, f)
+	if calls != 1 {
+		t.Errorf("want calls==1, got %d", calls)
+	}
+	if allocs != 0 {
+		t.Errorf("want 0 allocations per call, got %v", allocs)
+	}
+}
+
+func TestOnceFuncDebugOff(t *testing.T) {
+	DebugIsOn = false
+	testOnceFunc(t)
+}
+
+func TestOnceFuncDebugOn(t *testing.T) {
+	DebugIsOn = true
+	testOnceFunc(t)
+}
+
+func testOnceValues(t *testing.T) {
+	calls := 0
+	value := OnceValue(func() int {
+		calls++
+		return calls
+	})
+	values := OnceValues(func() (int, int) {
+		calls++
+		return calls, calls + 1
+	})
+
+	for i := 0; i < 2; i++ {
+		if v := value(); v != 1 {
+			t.Errorf("want 1, got %d", v)
+		}
+		if v1, v2 := values(); v1 != 2 || v2 != 3 {
+			t.Errorf("want 2 and 3, got %d and %d", v1, v2)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("want calls==2, got %d", calls)
+	}
+}
+
+func TestOnceValuesDebugOff(t *testing.T) {
+	DebugIsOn = false
+	testOnceValues(t)
+}
+
+func TestOnceValuesDebugOn(t *testing.T) {
+	DebugIsOn = true
+	testOnceValues(t)
+}
+
+func testOnceFuncPanic(t *testing.T) {
+	calls := 0
+	f := OnceValue(func() int {
+		calls++
+		panic("x")
+	})
+
+	for i := 0; i < 2; i++ {
+		out := recoverFrom(func() { f() })
+		if out != "x" {
+			t.Fatalf("call %d: want panic x, got %q", i, out)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("want calls==1, got %d", calls)
+	}
+}
+
+func TestOnceFuncPanicDebugOff(t *testing.T) {
+	DebugIsOn = false
+	testOnceFuncPanic(t)
+}
+
+func TestOnceFuncPanicDebugOn(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+	testOnceFuncPanic(t)
+}
+
+func TestSelfDeadlockRecursiveOnceFunc(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+
+	var f func()
+	f = OnceFunc(func() { f() })
+
+	expectMisusePanic(t, "self-deadlock: recursive Once.Do", f)
+}
+
+func TestOnceFuncTimeout(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+
+	var collector report.Collector
+	f := OnceFunc(func() { time.Sleep(50 * time.Millisecond) },
+		WithName("slow-init"), WithTimeout(10*time.Millisecond), WithReporter(&collector))
+
+	f()
+
+	events := collector.Events()
+	if len(events) != 1 || events[0].Kind != report.KindHoldTimeout || events[0].Name != "slow-init" {
+		t.Fatalf("unexpected reports: %v", events)
+	}
+}
@@ -1,11 +1,250 @@
 package sync
 
 import (
-	_sync "sync"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"go.dedis.ch/debugtools/internal/goroutine"
 )
 
+// Poisoner is implemented by the objects of a Pool which can be poisoned when
+// put back while debugging is on, so that using them afterwards fails
+// deterministically. A poisoned object is not reused by the Pool.
+type Poisoner interface {
+	// Poison makes the object unusable, e.g. by setting its fields to values
+	// which make any further use panic.
+	Poison()
+}
+
 // A Pool is a set of temporary objects that may be individually saved
 // and retrieved.
+//
+// The objects reused, missing and created by Get are counted per name of the
+// pools, available with Pools.
+//
+// When debugging is on, the objects given by Get and not put back yet are
+// counted per call site of Get, and the ones implementing Poisoner are
+// poisoned when put back. Only the objects which are pointers, maps, slices
+// or channels can be tracked.
+//
+// A Pool must not be copied after first use.
 type Pool struct {
-	_sync.Pool
+	// New optionally specifies a function to generate
+	// a value when Get would otherwise return nil.
+	// It may not be changed concurrently with calls to Get.
+	New func() any
+
+	pool  sync.Pool
+	mutex sync.Mutex
+	// sites are the call sites of Get of the objects handed out and not put
+	// back yet, by address.
+	sites map[uintptr]string
+	cfg   *config
+	// stats are the statistics of the pools of the same name, or nil for the
+	// unnamed ones.
+	stats *poolStatistics
+}
+
+// NewPool creates a Pool generating its values with newFunc, configured with
+// the given options.
+// A Pool created this way can be copied before its first use.
+func NewPool(newFunc func() any, opts ...Option) *Pool {
+	pool := newPool(opts)
+	pool.New = newFunc
+
+	return &pool
+}
+
+func newPool(opts []Option) Pool {
+	cfg := newConfig(opts)
+
+	return Pool{cfg: cfg, stats: statistics.pool(cfg.name)}
+}
+
+// Put adds x to the pool.
+func (p *Pool) Put(x any) {
+	if x == nil {
+		return
+	}
+
+	if DebugIsOn {
+		p.putBack(x)
+
+		poisoner, ok := x.(Poisoner)
+		if ok {
+			poisoner.Poison()
+			return
+		}
+	}
+
+	p.pool.Put(x)
+}
+
+// Get selects an arbitrary item from the Pool, removes it from the
+// Pool, and returns it to the caller.
+// Get may choose to ignore the pool and treat it as empty.
+// Callers should not assume any relation between values passed to Put and
+// the values returned by Get.
+//
+// If Get would otherwise return nil and p.New is non-nil, Get returns
+// the result of calling p.New.
+func (p *Pool) Get() any {
+	x := p.take()
+	if x == nil && p.New != nil {
+		x = p.New()
+		atomic.AddUint64(&p.statistics().counters.allocations, 1)
+	}
+
+	if DebugIsOn && x != nil {
+		p.handOut(x)
+	}
+
+	return x
+}
+
+// take takes an object from the pool, if any, and counts a hit or a miss.
+func (p *Pool) take() any {
+	x := p.pool.Get()
+
+	if x != nil {
+		atomic.AddUint64(&p.statistics().counters.hits, 1)
+	} else {
+		atomic.AddUint64(&p.statistics().counters.misses, 1)
+	}
+
+	return x
+}
+
+func (p *Pool) statistics() *poolStatistics {
+	if p.stats == nil {
+		return &unnamedPool
+	}
+
+	return p.stats
+}
+
+// handOut records that x has been given by Get.
+func (p *Pool) handOut(x any) {
+	address, ok := addressOf(x)
+	if !ok {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.sites == nil {
+		p.sites = make(map[uintptr]string)
+	}
+
+	previous, found := p.sites[address]
+	if found {
+		// the object has been collected and its address reused
+		statistics.handOut(p.statistics(), previous, -1)
+	}
+
+	site := goroutine.Caller(packagePrefix)
+	p.sites[address] = site
+	statistics.handOut(p.statistics(), site, 1)
+}
+
+// putBack records that x has been put back, if it has been given by Get.
+func (p *Pool) putBack(x any) {
+	address, ok := addressOf(x)
+	if !ok {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	site, found := p.sites[address]
+	if !found {
+		return
+	}
+
+	delete(p.sites, address)
+	statistics.handOut(p.statistics(), site, -1)
+}
+
+// addressOf returns the address identifying x, if it has one.
+func addressOf(x any) (uintptr, bool) {
+	v := reflect.ValueOf(x)
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.UnsafePointer, reflect.Map, reflect.Slice, reflect.Chan:
+		return v.Pointer(), v.Pointer() != 0
+	default:
+		return 0, false
+	}
+}
+
+// isNil tells whether x is nil, or a nil value of a type which can be nil.
+func isNil(x any) bool {
+	if x == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(x)
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.UnsafePointer, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+// TypedPool is a Pool of objects of type T. It is instrumented and counted as
+// a Pool.
+//
+// A TypedPool must not be copied after first use.
+type TypedPool[T any] struct {
+	// New optionally specifies a function to generate
+	// a value when Get would otherwise return the zero value of T.
+	// It may not be changed concurrently with calls to Get.
+	New func() T
+
+	pool Pool
+}
+
+// NewTypedPool creates a TypedPool generating its values with newFunc,
+// configured with the given options.
+// A TypedPool created this way can be copied before its first use.
+func NewTypedPool[T any](newFunc func() T, opts ...Option) *TypedPool[T] {
+	return &TypedPool[T]{New: newFunc, pool: newPool(opts)}
+}
+
+// Put adds x to the pool. As with Pool.Put, a nil x, such as a nil pointer,
+// is dropped, so that Get calls New instead of returning it.
+func (p *TypedPool[T]) Put(x T) {
+	if isNil(x) {
+		return
+	}
+
+	p.pool.Put(x)
+}
+
+// Get selects an arbitrary item from the pool, removes it from the pool, and
+// returns it to the caller, as Pool.Get.
+//
+// If the pool is empty, Get returns the result of calling p.New, or the zero
+// value of T if p.New is nil.
+func (p *TypedPool[T]) Get() T {
+	var x T
+
+	taken := p.pool.take()
+	if taken != nil {
+		x = taken.(T)
+	} else if p.New != nil {
+		x = p.New()
+		atomic.AddUint64(&p.pool.statistics().counters.allocations, 1)
+	}
+
+	if DebugIsOn {
+		p.pool.handOut(x)
+	}
+
+	return x
 }
@@ -0,0 +1,182 @@
+package sync
+
+import (
+	"testing"
+
+	"go.dedis.ch/debugtools/internal/testutil"
+)
+
+type buffer struct {
+	data     []byte
+	poisoned bool
+}
+
+func (b *buffer) Poison() {
+	b.data = nil
+	b.poisoned = true
+}
+
+func poolStatsOf(name string) (PoolStats, bool) {
+	for _, p := range Pools() {
+		if p.Name == name {
+			return p, true
+		}
+	}
+
+	return PoolStats{}, false
+}
+
+func testPool(t *testing.T) {
+	p := NewPool(func() any { return "new" })
+
+	if x := p.Get(); x != "new" {
+		t.Fatalf("unexpected value from New: %v", x)
+	}
+
+	var empty Pool
+	if x := empty.Get(); x != nil {
+		t.Fatalf("unexpected value from an empty pool: %v", x)
+	}
+
+	empty.Put(nil)
+}
+
+func TestPoolDebugOff(t *testing.T) {
+	DebugIsOn = false
+	testPool(t)
+
+	name := testutil.UniqueName(t)
+	p := NewPool(func() any { return new(buffer) }, WithName(name))
+	p.Put(p.Get())
+	_ = p.Get()
+
+	stats, _ := poolStatsOf(name)
+	if stats.Hits+stats.Misses != 2 || stats.Allocations != stats.Misses {
+		t.Fatalf("objects not counted while debugging is off: %v", stats)
+	}
+	if stats.Outstanding != 0 || len(stats.Sites) != 0 {
+		t.Fatalf("objects tracked while debugging is off: %v", stats)
+	}
+}
+
+func TestPoolDebugOn(t *testing.T) {
+	DebugIsOn = true
+	testPool(t)
+}
+
+func getBuffers(p *Pool, n int) []any {
+	objects := make([]any, n)
+	for i := range objects {
+		objects[i] = p.Get()
+	}
+
+	return objects
+}
+
+func TestPoolOutstanding(t *testing.T) {
+	DebugIsOn = true
+
+	name := testutil.UniqueName(t)
+	p := NewPool(func() any { return &buffer{} }, WithName(name))
+
+	few := getBuffers(p, 2)
+	many := getBuffers(p, 3)
+	p.Put(few[0])
+	p.Put(many[0])
+	p.Put(many[0])
+
+	stats, found := poolStatsOf(name)
+	if !found || stats.Outstanding != 3 || len(stats.Sites) != 1 {
+		t.Fatalf("unexpected stats: %v", stats)
+	}
+
+	p.Put(few[1])
+	p.Put(p.Get())
+	p.Put(many[1])
+	p.Put(many[2])
+
+	stats, _ = poolStatsOf(name)
+	if stats.Outstanding != 0 || len(stats.Sites) != 0 {
+		t.Fatalf("unexpected stats: %v", stats)
+	}
+}
+
+func TestPoolPoison(t *testing.T) {
+	DebugIsOn = true
+
+	p := NewPool(func() any { return &buffer{data: make([]byte, 8)} })
+
+	b := p.Get().(*buffer)
+	p.Put(b)
+
+	if !b.poisoned || b.data != nil {
+		t.Fatal("object not poisoned")
+	}
+
+	if p.Get().(*buffer).poisoned {
+		t.Fatal("poisoned object reused")
+	}
+}
+
+func testTypedPool(t *testing.T, name string) {
+	p := NewTypedPool(func() *buffer { return &buffer{} }, WithName(name))
+
+	b := p.Get()
+	b.data = append(b.data, 'a')
+	p.Put(b)
+
+	if b := p.Get(); b == nil {
+		t.Fatal("no object from New")
+	}
+
+	var empty TypedPool[*buffer]
+	if b := empty.Get(); b != nil {
+		t.Fatalf("unexpected object from an empty pool: %v", b)
+	}
+}
+
+func TestTypedPoolDebugOff(t *testing.T) {
+	DebugIsOn = false
+	testTypedPool(t, testutil.UniqueName(t))
+}
+
+func TestTypedPoolDebugOn(t *testing.T) {
+	DebugIsOn = true
+
+	name := testutil.UniqueName(t)
+	testTypedPool(t, name)
+
+	stats, _ := poolStatsOf(name)
+	if stats.Outstanding != 1 || len(stats.Sites) != 1 {
+		t.Fatalf("unexpected stats: %v", stats)
+	}
+}
+
+func TestTypedPoolPutNil(t *testing.T) {
+	DebugIsOn = false
+
+	p := NewTypedPool(func() *buffer { return &buffer{} }, WithName(testutil.UniqueName(t)))
+
+	p.Put(nil)
+	if b := p.Get(); b == nil {
+		t.Fatal("nil object given back by the pool")
+	}
+}
+
+func TestTypedPoolCounters(t *testing.T) {
+	DebugIsOn = true
+
+	// objects which are not poisoned, to be reused
+	name := testutil.UniqueName(t)
+	p := NewTypedPool(func() []byte { return make([]byte, 0, 8) }, WithName(name))
+
+	// the objects may be dropped by the runtime at any time
+	for i := 0; i < 10; i++ {
+		p.Put(p.Get())
+	}
+
+	stats, _ := poolStatsOf(name)
+	if stats.Hits+stats.Misses != 10 || stats.Allocations != stats.Misses || stats.Misses == 0 {
+		t.Fatalf("unexpected counters: %v", stats)
+	}
+}
@@ -0,0 +1,14 @@
+package sync
+
+import (
+	"runtime/pprof"
+)
+
+// The profiles of the goroutines holding or waiting on the debug primitives,
+// populated while debugging is on. They can be read with go tool pprof, e.g.
+// from net/http/pprof at /debug/pprof/debugsync.waiting. The entries of the
+// trackers are added with their id as the key.
+var (
+	heldProfile    = pprof.NewProfile("debugsync.held")
+	waitingProfile = pprof.NewProfile("debugsync.waiting")
+)
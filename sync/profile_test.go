@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+)
+
+func profiled(name string) string {
+	var b strings.Builder
+
+	_ = pprof.Lookup(name).WriteTo(&b, 1)
+
+	return b.String()
+}
+
+func TestProfilesDebugOff(t *testing.T) {
+	DebugIsOn = false
+
+	var m Mutex
+
+	m.Lock()
+	defer m.Unlock()
+
+	if strings.Contains(profiled("debugsync.held"), "TestProfilesDebugOff") {
+		t.Fatal("lock profiled while debugging is off")
+	}
+}
+
+func TestProfilesDebugOn(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+
+	var m RWMutex
+
+	m.Lock()
+	if !strings.Contains(profiled("debugsync.held"), "TestProfilesDebugOn") {
+		t.Fatalf("holder not profiled: %s", profiled("debugsync.held"))
+	}
+
+	go func() {
+		m.RLock()
+		m.RUnlock()
+	}()
+
+	for !strings.Contains(profiled("debugsync.waiting"), "TestProfilesDebugOn.func1") {
+		time.Sleep(time.Millisecond)
+	}
+
+	m.Unlock()
+
+	for strings.Contains(profiled("debugsync.waiting"), "TestProfilesDebugOn.func1") {
+		time.Sleep(time.Millisecond)
+	}
+
+	if strings.Contains(profiled("debugsync.held"), "TestProfilesDebugOn") {
+		t.Fatalf("holder still profiled: %s", profiled("debugsync.held"))
+	}
+}
@@ -0,0 +1,56 @@
+package sync
+
+import (
+	"fmt"
+
+	"go.dedis.ch/debugtools/report"
+)
+
+// OnRankViolation is the action taken in the offending goroutine when it
+// acquires a lock of a lower rank than one it holds, once the event has been
+// reported.
+var OnRankViolation = report.ActionLog
+
+// outranking returns the lock of the highest rank held by the goroutine gid
+// which outranks the given lock, if any.
+func (o *lockOrder) outranking(gid uint64, lock heldLock) (heldLock, bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	var highest heldLock
+
+	for _, h := range o.held[gid] {
+		if h.id != lock.id && h.rank > lock.rank && h.rank > highest.rank {
+			highest = h
+		}
+	}
+
+	return highest, highest.rank > 0
+}
+
+// checkRank reports that the goroutine gid is acquiring the lock of the given
+// kind while holding one of a higher rank, if it is ranked, and takes the
+// OnRankViolation action.
+func checkRank(c *config, kind string, gid uint64, lock heldLock) {
+	if lock.rank == 0 {
+		return
+	}
+
+	held, found := order.outranking(gid, lock)
+	if !found {
+		return
+	}
+
+	msg := fmt.Sprintf("lock rank violation: %s of rank %d acquired while holding %s of rank %d",
+		lock.name, lock.rank, held.name, held.rank)
+	e := newEvent(report.KindLockRank, kind, lock.name, msg, gid, lock.stack)
+	e.Related = []report.Goroutine{{
+		ID:    gid,
+		Role:  fmt.Sprintf("%s of rank %d acquired by", held.name, held.rank),
+		Stack: string(held.stack),
+	}}
+
+	action := OnRankViolation
+	c.emit(e)
+	takeAction(action, e)
+}
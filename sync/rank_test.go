@@ -0,0 +1,112 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.dedis.ch/debugtools/report"
+)
+
+// rankEvents returns the lock-rank events collected.
+func rankEvents(collector *report.Collector) []report.Event {
+	var events []report.Event
+
+	for _, e := range collector.Events() {
+		if e.Kind == report.KindLockRank {
+			events = append(events, e)
+		}
+	}
+
+	return events
+}
+
+func TestLockRankDebugOff(t *testing.T) {
+	DebugIsOn = false
+
+	var collector report.Collector
+	state := NewMutex(WithRank(1), WithReporter(&collector))
+	peers := NewMutex(WithRank(2), WithReporter(&collector))
+
+	peers.Lock()
+	state.Lock()
+	state.Unlock()
+	peers.Unlock()
+
+	if events := rankEvents(&collector); len(events) != 0 {
+		t.Fatalf("rank checked while debugging is off: %v", events)
+	}
+}
+
+func TestLockRankDebugOn(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+
+	var collector report.Collector
+	state := NewMutex(WithName("state"), WithRank(1), WithReporter(&collector))
+	peers := NewRWMutex(WithName("peers"), WithRank(2), WithReporter(&collector))
+	conn := NewMutex(WithName("conn"), WithRank(3), WithReporter(&collector))
+	unranked := NewMutex(WithReporter(&collector))
+
+	// in order, or with an unranked lock
+	state.Lock()
+	peers.RLock()
+	unranked.Lock()
+	conn.Lock()
+	conn.Unlock()
+	unranked.Unlock()
+	peers.RUnlock()
+	state.Unlock()
+
+	if events := rankEvents(&collector); len(events) != 0 {
+		t.Fatalf("unexpected rank violations: %v", events)
+	}
+
+	conn.Lock()
+	peers.Lock()
+	state.Lock()
+	state.Unlock()
+	peers.Unlock()
+	conn.Unlock()
+
+	events := rankEvents(&collector)
+	if len(events) != 2 {
+		t.Fatalf("unexpected rank violations: %v", events)
+	}
+
+	msg := "lock rank violation: state of rank 1 acquired while holding conn of rank 3"
+	if events[1].Message != msg {
+		t.Fatalf("unexpected report: %s", events[1].Message)
+	}
+	if !strings.Contains(events[1].String(), "conn of rank 3 acquired by goroutine") {
+		t.Fatalf("holder not reported: %s", events[1])
+	}
+}
+
+func TestLockRankPanic(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+
+	original := OnRankViolation
+	OnRankViolation = report.ActionPanic
+	defer func() { OnRankViolation = original }()
+
+	low := NewMutex(WithRank(1))
+	high := NewMutex(WithRank(2))
+
+	high.Lock()
+	defer high.Unlock()
+
+	out := func() (out string) {
+		defer func() { out = fmt.Sprint(recover()) }()
+
+		low.Lock()
+		low.Unlock()
+
+		return ""
+	}()
+
+	if !strings.Contains(out, "lock rank violation") {
+		t.Fatalf("unexpected panic: %s", out)
+	}
+}
@@ -1,8 +1,13 @@
 package sync
 
 import (
+	"context"
 	"runtime/debug"
 	"sync"
+	"time"
+
+	"go.dedis.ch/debugtools/internal/goroutine"
+	"go.dedis.ch/debugtools/report"
 )
 
 // A RWMutex is a reader/writer mutual exclusion lock.
@@ -27,9 +32,16 @@ import (
 // the n+1'th call to Lock.
 type RWMutex struct {
 	mutex     sync.RWMutex
-	wg        sync.WaitGroup
-	wgStarted bool
 	unlocking chan struct{}
+	id        uint64
+	tracker   tracker
+	cfg       *config
+}
+
+// NewRWMutex creates a RWMutex configured with the given options.
+// A RWMutex created this way can be copied before its first use.
+func NewRWMutex(opts ...Option) *RWMutex {
+	return &RWMutex{cfg: newConfig(opts)}
 }
 
 // Lock locks rw for writing.
@@ -37,16 +49,41 @@ type RWMutex struct {
 // Lock blocks until the lock is available.
 func (m *RWMutex) Lock() {
 	if DebugIsOn {
-		locking := startLockTimer("RWMutex timed out when acquiring lock", debug.Stack())
-		m.mutex.Lock()
-		close(locking)
-
-		m.unlocking = startLockTimer("RWMutex timed out before releasing lock", debug.Stack())
+		_ = m.debugLock(context.Background())
 	} else {
 		m.mutex.Lock()
 	}
 }
 
+// LockContext locks rw for writing, unless ctx is done before the lock is
+// available. In that case, a *LockError wrapping the error of the context is
+// returned.
+//
+// As with Lock, a blocked LockContext call excludes new readers from
+// acquiring the lock. When it gives up, the acquisition is completed in the
+// background and the lock is released right away.
+func (m *RWMutex) LockContext(ctx context.Context) error {
+	if DebugIsOn {
+		return m.debugLock(ctx)
+	}
+
+	err := acquireContext(ctx, m.mutex.TryLock, m.mutex.Lock, m.mutex.Unlock)
+	if err != nil {
+		return &LockError{Name: m.name(), Err: err}
+	}
+
+	return nil
+}
+
+// LockTimeout locks rw for writing, unless the lock is not available after
+// the given timeout. In that case, a *LockError is returned.
+func (m *RWMutex) LockTimeout(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return m.LockContext(ctx)
+}
+
 // TryLock tries to lock rw for writing and reports whether it succeeded.
 //
 // Note that while correct uses of TryLock do exist, they are rare,
@@ -56,7 +93,10 @@ func (m *RWMutex) TryLock() bool {
 	locked := m.mutex.TryLock()
 
 	if DebugIsOn && locked {
-		m.unlocking = startLockTimer("RWMutex timed out before releasing lock", debug.Stack())
+		gid, stack := goroutine.ID(), debug.Stack()
+		order.acquired(gid, m.heldLock(false, stack))
+		m.track().hold(gid, modeLock, stack, nil)
+		m.unlocking = m.startHoldTimer("RWMutex timed out before releasing lock", gid, stack)
 	}
 
 	return locked
@@ -72,6 +112,14 @@ func (m *RWMutex) Unlock() {
 	if DebugIsOn && m.unlocking != nil {
 		close(m.unlocking)
 		m.unlocking = nil
+
+		gid := goroutine.ID()
+		order.released(gid, m.lockID(), false)
+
+		held, found := m.tracker.release(gid, modeLock)
+		if found {
+			recordHold(m.cfg, "RWMutex", held)
+		}
 	}
 	m.mutex.Unlock()
 }
@@ -96,16 +144,37 @@ func (m *RWMutex) Unlock() {
 // documentation on the RWMutex type.
 func (m *RWMutex) RLock() {
 	if DebugIsOn {
-		locking := startLockTimer("RWMutex timed out when acquiring RLock", debug.Stack())
-		m.mutex.RLock()
-		close(locking)
-
-		m.startRLockTimer("RMutex timed out before releasing RLock", debug.Stack())
+		_ = m.debugRLock(context.Background())
 	} else {
 		m.mutex.RLock()
 	}
 }
 
+// RLockContext locks rw for reading, unless ctx is done before the lock is
+// available. In that case, a *LockError wrapping the error of the context is
+// returned.
+func (m *RWMutex) RLockContext(ctx context.Context) error {
+	if DebugIsOn {
+		return m.debugRLock(ctx)
+	}
+
+	err := acquireContext(ctx, m.mutex.TryRLock, m.mutex.RLock, m.mutex.RUnlock)
+	if err != nil {
+		return &LockError{Name: m.name(), Err: err}
+	}
+
+	return nil
+}
+
+// RLockTimeout locks rw for reading, unless the lock is not available after
+// the given timeout. In that case, a *LockError is returned.
+func (m *RWMutex) RLockTimeout(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return m.RLockContext(ctx)
+}
+
 // TryRLock tries to lock rw for reading and reports whether it succeeded.
 //
 // Note that while correct uses of TryRLock do exist, they are rare,
@@ -114,7 +183,10 @@ func (m *RWMutex) RLock() {
 func (m *RWMutex) TryRLock() bool {
 	locked := m.mutex.TryRLock()
 	if DebugIsOn && locked {
-		m.startRLockTimer("RMutex timed out before releasing RLock", debug.Stack())
+		gid, stack := goroutine.ID(), debug.Stack()
+		order.acquired(gid, m.heldLock(true, stack))
+		unlocking := m.startHoldTimer("RWMutex timed out before releasing RLock", gid, stack)
+		m.track().hold(gid, modeRLock, stack, unlocking)
 	}
 	return locked
 }
@@ -124,33 +196,172 @@ func (m *RWMutex) TryRLock() bool {
 // It is a run-time error if rw is not locked for reading
 // on entry to RUnlock.
 func (m *RWMutex) RUnlock() {
-	defer m.mutex.RUnlock()
-
 	if DebugIsOn {
-		m.wg.Done()
-		defer func() {
-			err := recover()
-			if err != nil {
-				// when the WaitGroup goes bellow zero,
-				// we need to recover from panic and re-initialize it
-				m.wg = sync.WaitGroup{}
+		gid := goroutine.ID()
+		order.released(gid, m.lockID(), true)
+
+		held, found := m.tracker.release(gid, modeRLock)
+		if found {
+			recordHold(m.cfg, "RWMutex", held)
+
+			if held.timer != nil {
+				close(held.timer)
 			}
-		}()
+		}
 	}
+	m.mutex.RUnlock()
 }
 
-func (m *RWMutex) startRLockTimer(msg string, stack []byte) {
-	m.wg.Add(1)
+func (m *RWMutex) debugLock(ctx context.Context) error {
+	m.cfg.getLogger().Debug().Str("name", m.name()).Msg("Locking")
+	gid, stack := goroutine.ID(), debug.Stack()
+	held, self := order.acquire(gid, m.lockID(), m.name(), false, stack)
+	if self {
+		msg := "self-deadlock: recursive RWMutex.Lock"
+		if held.read {
+			msg = "self-deadlock: RWMutex.Lock while holding a read lock"
+		}
+
+		raiseMisuse(m.cfg, withHolder(m.event(report.KindSelfDeadlock, msg, gid, stack), gid, held))
+	} else {
+		checkRank(m.cfg, "RWMutex", gid, m.heldLock(false, stack))
+	}
+	// the wait starts before the goroutine is seen waiting
+	start := time.Now()
+	m.track().wait(gid, modeLock, stack)
+
+	e := m.event(report.KindTimeout, "RWMutex timed out when acquiring lock", gid, stack)
+	ctx, abort := waitContext(ctx)
+	if abort != nil {
+		defer abort(nil)
+	}
+
+	locking := startWaitTimer(m.cfg, e, &m.tracker, abort)
+	err := acquireContext(ctx, m.mutex.TryLock, m.mutex.Lock, m.mutex.Unlock)
+	close(locking)
+	recordWait(m.cfg, "RWMutex", modeLock, stack, time.Since(start))
+
+	if err != nil {
+		m.tracker.stopWaiting(gid, modeLock)
+		panicOnTimeout(ctx)
+		return &LockError{Name: m.name(), Err: err, Holders: m.tracker.String()}
+	}
+
+	order.acquired(gid, m.heldLock(false, stack))
+	m.tracker.hold(gid, modeLock, stack, nil)
+	m.unlocking = m.startHoldTimer("RWMutex timed out before releasing lock", gid, stack)
+
+	return nil
+}
+
+func (m *RWMutex) debugRLock(ctx context.Context) error {
+	m.cfg.getLogger().Debug().Str("name", m.name()).Msg("Read locking")
+	gid, stack := goroutine.ID(), debug.Stack()
+	held, self := order.acquire(gid, m.lockID(), m.name(), true, stack)
+	if self && !held.read {
+		e := m.event(report.KindSelfDeadlock, "self-deadlock: RWMutex.RLock while holding the lock", gid, stack)
+		raiseMisuse(m.cfg, withHolder(e, gid, held))
+	} else if self {
+		m.reportRecursiveRLock(gid, held, stack)
+	} else {
+		checkRank(m.cfg, "RWMutex", gid, m.heldLock(true, stack))
+	}
+	// the wait starts before the goroutine is seen waiting
+	start := time.Now()
+	m.track().wait(gid, modeRLock, stack)
+
+	e := m.event(report.KindTimeout, "RWMutex timed out when acquiring RLock", gid, stack)
+	ctx, abort := waitContext(ctx)
+	if abort != nil {
+		defer abort(nil)
+	}
+
+	locking := startWaitTimer(m.cfg, e, &m.tracker, abort)
+	err := acquireContext(ctx, m.mutex.TryRLock, m.mutex.RLock, m.mutex.RUnlock)
+	close(locking)
+	recordWait(m.cfg, "RWMutex", modeRLock, stack, time.Since(start))
+
+	if err != nil {
+		m.tracker.stopWaiting(gid, modeRLock)
+		panicOnTimeout(ctx)
+		return &LockError{Name: m.name(), Err: err, Holders: m.tracker.String()}
+	}
+
+	order.acquired(gid, m.heldLock(true, stack))
+	unlocking := m.startHoldTimer("RWMutex timed out before releasing RLock", gid, stack)
+	m.tracker.hold(gid, modeRLock, stack, unlocking)
+
+	return nil
+}
+
+// reportRecursiveRLock warns that the goroutine gid read locks m again while
+// already holding a read lock on it. This deadlocks as soon as a writer is
+// waiting for the lock, in which case a full misuse report is made instead.
+func (m *RWMutex) reportRecursiveRLock(gid uint64, held heldLock, stack []byte) {
+	writers := m.tracker.waiting(modeLock)
+	if len(writers) > 0 {
+		e := m.event(report.KindSelfDeadlock, "self-deadlock: recursive RWMutex.RLock with a pending writer", gid, stack)
+		e = withHolder(e, gid, held)
+		e.Related = append(e.Related, writers...)
+		raiseMisuse(m.cfg, e)
 
-	if m.wgStarted {
 		return
 	}
 
-	m.wgStarted = true
+	e := m.event(report.KindMisuse, "recursive RWMutex.RLock deadlocks if a writer is waiting", gid, stack)
+	e.Severity = report.SeverityWarning
+	m.cfg.emit(withHolder(e, gid, held))
+}
+
+// AssertHeld checks that rw is locked for writing by the calling goroutine,
+// if debugging is on. Otherwise, it does nothing.
+func (m *RWMutex) AssertHeld() {
+	if DebugIsOn {
+		assertHeld(m.cfg, "RWMutex", m.name(), m.lockID(), false)
+	}
+}
+
+// AssertRHeld checks that rw is locked for reading or writing by the calling
+// goroutine, if debugging is on. Otherwise, it does nothing.
+func (m *RWMutex) AssertRHeld() {
+	if DebugIsOn {
+		assertHeld(m.cfg, "RWMutex", m.name(), m.lockID(), true)
+	}
+}
+
+// AssertNotHeld checks that rw is not locked by the calling goroutine, if
+// debugging is on. Otherwise, it does nothing.
+func (m *RWMutex) AssertNotHeld() {
+	if DebugIsOn {
+		assertNotHeld(m.cfg, "RWMutex", m.name(), m.lockID())
+	}
+}
+
+// heldLock describes m held by a goroutine from the given stack, for reading
+// if read is set.
+func (m *RWMutex) heldLock(read bool, stack []byte) heldLock {
+	return heldLock{id: m.lockID(), name: m.name(), read: read, rank: m.cfg.getRank(), stack: stack}
+}
+
+func (m *RWMutex) lockID() uint64 {
+	return lazyLockID(&m.id)
+}
+
+func (m *RWMutex) name() string {
+	return m.cfg.nameOr(lockName("RWMutex", m.lockID()))
+}
+
+func (m *RWMutex) event(kind report.Kind, msg string, gid uint64, stack []byte) report.Event {
+	return newEvent(kind, "RWMutex", m.name(), msg, gid, stack)
+}
+
+// startHoldTimer starts the timer reporting with msg that the goroutine gid
+// holds m for too long.
+func (m *RWMutex) startHoldTimer(msg string, gid uint64, stack []byte) chan struct{} {
+	return startLockTimer(m.cfg, m.event(report.KindHoldTimeout, msg, gid, stack), &m.tracker)
+}
 
-	done := startLockTimer(msg, stack)
-	go func() {
-		m.wg.Wait()
-		close(done)
-	}()
+func (m *RWMutex) track() *tracker {
+	m.tracker.identify("RWMutex", m.name(), nil, m.cfg.getHistorySize())
+	return &m.tracker
 }
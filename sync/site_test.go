@@ -0,0 +1,181 @@
+package sync_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.dedis.ch/debugtools/internal/testutil"
+	"go.dedis.ch/debugtools/report"
+	"go.dedis.ch/debugtools/sync"
+)
+
+// The call sites are checked from outside the package, since all the frames
+// of the package are skipped when looking for them.
+
+const testPackage = "go.dedis.ch/debugtools/sync_test."
+
+func expectSite(t *testing.T, site, function string) {
+	t.Helper()
+
+	if !strings.HasPrefix(site, testPackage+function+" site_test.go:") {
+		t.Fatalf("site not in %s: %s", function, site)
+	}
+}
+
+func lockStatsOf(kind, name string) (sync.LockStats, bool) {
+	for _, s := range sync.Stats() {
+		if s.Kind == kind && s.Name == name {
+			return s, true
+		}
+	}
+
+	return sync.LockStats{}, false
+}
+
+func poolStatsOf(name string) (sync.PoolStats, bool) {
+	for _, p := range sync.Pools() {
+		if p.Name == name {
+			return p, true
+		}
+	}
+
+	return sync.PoolStats{}, false
+}
+
+func TestStatsSites(t *testing.T) {
+	sync.DebugIsOn = true
+	sync.ResetStats()
+
+	m := sync.NewRWMutex(sync.WithName("sites"))
+	m.Lock()
+	m.Unlock() //nolint:staticcheck // SA2001: empty critical section IGNORED !
+	m.RLock()
+	m.RUnlock() //nolint:staticcheck // SA2001: empty critical section IGNORED !
+
+	stats, _ := lockStatsOf("RWMutex", "sites")
+	if len(stats.Sites) != 2 {
+		t.Fatalf("unexpected sites: %v", stats.Sites)
+	}
+
+	for _, site := range stats.Sites {
+		expectSite(t, site.Site, "TestStatsSites")
+	}
+}
+
+func TestGuardedSites(t *testing.T) {
+	sync.DebugIsOn = true
+	sync.ResetStats()
+
+	g := sync.NewGuarded(0, sync.WithName("guarded-sites"))
+	g.With(func(x *int) { *x++ })
+
+	stats, _ := lockStatsOf("Mutex", "guarded-sites")
+	if len(stats.Sites) != 1 {
+		t.Fatalf("unexpected sites: %v", stats.Sites)
+	}
+
+	expectSite(t, stats.Sites[0].Site, "TestGuardedSites")
+}
+
+func TestHistorySites(t *testing.T) {
+	sync.DebugIsOn = true
+
+	var collector report.Collector
+	m := sync.NewMutex(sync.WithTimeout(10*time.Millisecond), sync.WithReporter(&collector),
+		sync.WithLogger(zerolog.Nop()))
+
+	m.Lock()
+	time.AfterFunc(50*time.Millisecond, m.Unlock)
+	m.Lock()
+	m.Unlock()
+
+	var history []report.Record
+	for _, e := range collector.Events() {
+		if e.Kind == report.KindTimeout {
+			history = e.History
+		}
+	}
+
+	if len(history) == 0 {
+		t.Fatalf("no history reported: %v", collector.Events())
+	}
+
+	for _, r := range history {
+		expectSite(t, r.Site, "TestHistorySites")
+	}
+}
+
+func TestOnceFuncSites(t *testing.T) {
+	sync.DebugIsOn = true
+
+	var collector report.Collector
+	f := sync.OnceFunc(func() { time.Sleep(50 * time.Millisecond) },
+		sync.WithTimeout(10*time.Millisecond), sync.WithReporter(&collector))
+
+	f()
+
+	events := collector.Events()
+	if len(events) != 1 || len(events[0].History) == 0 {
+		t.Fatalf("unexpected reports: %v", events)
+	}
+
+	for _, r := range events[0].History {
+		expectSite(t, r.Site, "TestOnceFuncSites")
+	}
+}
+
+type buffer struct {
+	data []byte
+}
+
+func getBuffers(p *sync.Pool, n int) []any {
+	objects := make([]any, n)
+	for i := range objects {
+		objects[i] = p.Get()
+	}
+
+	return objects
+}
+
+func TestPoolSites(t *testing.T) {
+	sync.DebugIsOn = true
+
+	name := testutil.UniqueName(t)
+	p := sync.NewPool(func() any { return &buffer{} }, sync.WithName(name))
+
+	held := p.Get()
+	buffers := getBuffers(p, 2)
+
+	stats, _ := poolStatsOf(name)
+	if len(stats.Sites) != 2 || stats.Sites[0].Outstanding != 2 {
+		t.Fatalf("unexpected sites: %v", stats.Sites)
+	}
+
+	expectSite(t, stats.Sites[0].Site, "getBuffers")
+	expectSite(t, stats.Sites[1].Site, "TestPoolSites")
+
+	p.Put(held)
+	for _, b := range buffers {
+		p.Put(b)
+	}
+}
+
+func TestTypedPoolSites(t *testing.T) {
+	sync.DebugIsOn = true
+
+	name := testutil.UniqueName(t)
+	p := sync.NewTypedPool(func() *buffer { return &buffer{} }, sync.WithName(name))
+
+	b := p.Get()
+
+	stats, _ := poolStatsOf(name)
+	if len(stats.Sites) != 1 {
+		t.Fatalf("unexpected sites: %v", stats.Sites)
+	}
+
+	expectSite(t, stats.Sites[0].Site, "TestTypedPoolSites")
+
+	p.Put(b)
+}
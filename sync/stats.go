@@ -0,0 +1,492 @@
+package sync
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.dedis.ch/debugtools/report"
+)
+
+// histogramBounds are the upper bounds of the buckets of the histograms, in
+// powers of two from 1µs to about 16s.
+var histogramBounds = func() []time.Duration {
+	bounds := make([]time.Duration, 25)
+	for i := range bounds {
+		bounds[i] = time.Microsecond << i
+	}
+
+	return bounds
+}()
+
+// Histogram is a distribution of durations.
+type Histogram struct {
+	// Bounds are the inclusive upper bounds of the buckets, in increasing
+	// order. The last bucket, past the last bound, has no upper bound.
+	Bounds []time.Duration
+	// Counts gives the number of durations in each bucket, one more than the
+	// bounds.
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+	Max    time.Duration
+}
+
+func newHistogram() Histogram {
+	return Histogram{
+		Bounds: histogramBounds,
+		Counts: make([]uint64, len(histogramBounds)+1),
+	}
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	i := sort.Search(len(h.Bounds), func(i int) bool { return d <= h.Bounds[i] })
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+
+	if d > h.Max {
+		h.Max = d
+	}
+}
+
+func (h *Histogram) merge(other Histogram) {
+	for i, n := range other.Counts {
+		h.Counts[i] += n
+	}
+
+	h.Count += other.Count
+	h.Sum += other.Sum
+
+	if other.Max > h.Max {
+		h.Max = other.Max
+	}
+}
+
+func (h Histogram) clone() Histogram {
+	h.Counts = append([]uint64(nil), h.Counts...)
+	return h
+}
+
+// Mean returns the mean duration, or zero if the histogram is empty.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile returns an upper bound of the q-quantile, e.g. 0.99 for the 99th
+// percentile, which is the bound of the bucket where it falls.
+func (h Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+
+	rank := uint64(q * float64(h.Count))
+	if rank == 0 {
+		rank = 1
+	}
+
+	var seen uint64
+
+	for i, n := range h.Counts {
+		seen += n
+		if seen >= rank && i < len(h.Bounds) {
+			return min(h.Bounds[i], h.Max)
+		}
+	}
+
+	return h.Max
+}
+
+// SiteStats are the wait and hold times of the acquisitions of a lock from a
+// call site.
+type SiteStats struct {
+	// Site is the function acquiring the lock and its location, e.g.
+	// "main.(*Peers).Add peers.go:42".
+	Site string
+	// Mode is either "lock" or "read lock".
+	Mode string
+	Wait Histogram
+	Hold Histogram
+}
+
+// LockStats are the wait and hold times of a lock, over all its call sites.
+type LockStats struct {
+	// Kind is the type of the lock, e.g. "Mutex".
+	Kind string
+	// Name is the name given to the lock with WithName. The locks of a kind
+	// without a name share the same statistics, with an empty name.
+	Name  string
+	Wait  Histogram
+	Hold  Histogram
+	Sites []SiteStats
+}
+
+// EventCount is the number of events of a kind emitted so far by the
+// primitives of a name.
+type EventCount struct {
+	Primitive string
+	// Name is the name given to the primitives with WithName, or empty.
+	Name  string
+	Kind  report.Kind
+	Count uint64
+}
+
+// WaitGroupCounter is the total counter of the wait groups of a name.
+type WaitGroupCounter struct {
+	// Name is the name given to the wait groups with WithName, or empty.
+	Name    string
+	Counter int64
+}
+
+// PoolSite is the number of objects given by the Get calls of a call site,
+// and not put back yet.
+type PoolSite struct {
+	// Site is the function calling Get and its location, e.g.
+	// "main.(*Peers).Add peers.go:42".
+	Site        string
+	Outstanding int64
+}
+
+// PoolStats are the objects given by the pools of a name.
+type PoolStats struct {
+	// Name is the name given to the pools with WithName, or empty.
+	Name string
+	// Hits is the number of objects reused by Get, and Misses the number of
+	// calls to Get with an empty pool.
+	Hits   uint64
+	Misses uint64
+	// Allocations is the number of objects created with New.
+	Allocations uint64
+	Outstanding int64
+	// Sites are the call sites of Get, the ones with the most outstanding
+	// objects first.
+	Sites []PoolSite
+}
+
+// poolCounters counts the objects given by the pools of a name.
+type poolCounters struct {
+	hits        uint64
+	misses      uint64
+	allocations uint64
+}
+
+// poolStatistics are the counters of the pools of a name, and their
+// outstanding objects per call site of Get.
+type poolStatistics struct {
+	counters poolCounters
+	sites    map[string]int64
+}
+
+type statsKey struct {
+	kind, name string
+}
+
+type siteKey struct {
+	site, mode string
+}
+
+type eventKey struct {
+	primitive, name string
+	kind            report.Kind
+}
+
+// unnamedOutstanding is the total counter of the unnamed wait groups.
+var unnamedOutstanding int64
+
+// unnamedPool are the statistics of the unnamed pools.
+var unnamedPool = poolStatistics{sites: make(map[string]int64)}
+
+// primitiveStatistics records the wait and hold times of the debug locks, the
+// events emitted, the counters of the wait groups and the objects of the
+// pools.
+type primitiveStatistics struct {
+	mutex      sync.Mutex
+	locks      map[statsKey]map[siteKey]*SiteStats
+	events     map[eventKey]uint64
+	waitGroups map[string]*int64
+	pools      map[string]*poolStatistics
+}
+
+var statistics = primitiveStatistics{
+	locks:      make(map[statsKey]map[siteKey]*SiteStats),
+	events:     make(map[eventKey]uint64),
+	waitGroups: map[string]*int64{"": &unnamedOutstanding},
+	pools:      map[string]*poolStatistics{"": &unnamedPool},
+}
+
+// Stats returns a snapshot of the wait and hold times of the debug locks,
+// recorded while debugging is on, sorted by kind and name, and then by site.
+func Stats() []LockStats {
+	statistics.mutex.Lock()
+	defer statistics.mutex.Unlock()
+
+	locks := make([]LockStats, 0, len(statistics.locks))
+
+	for key, sites := range statistics.locks {
+		lock := LockStats{
+			Kind: key.kind,
+			Name: key.name,
+			Wait: newHistogram(),
+			Hold: newHistogram(),
+		}
+
+		for _, site := range sites {
+			lock.Wait.merge(site.Wait)
+			lock.Hold.merge(site.Hold)
+			lock.Sites = append(lock.Sites, SiteStats{
+				Site: site.Site,
+				Mode: site.Mode,
+				Wait: site.Wait.clone(),
+				Hold: site.Hold.clone(),
+			})
+		}
+
+		sort.Slice(lock.Sites, func(i, j int) bool {
+			a, b := lock.Sites[i], lock.Sites[j]
+			return a.Site < b.Site || a.Site == b.Site && a.Mode < b.Mode
+		})
+
+		locks = append(locks, lock)
+	}
+
+	sort.Slice(locks, func(i, j int) bool {
+		a, b := locks[i], locks[j]
+		return a.Kind < b.Kind || a.Kind == b.Kind && a.Name < b.Name
+	})
+
+	return locks
+}
+
+// ResetStats forgets the wait and hold times, and the events, recorded so
+// far. The counters of the wait groups and of the pools are kept.
+func ResetStats() {
+	statistics.mutex.Lock()
+	defer statistics.mutex.Unlock()
+
+	statistics.locks = make(map[statsKey]map[siteKey]*SiteStats)
+	statistics.events = make(map[eventKey]uint64)
+}
+
+// EventCounts returns the number of events emitted so far, sorted by
+// primitive, name and kind.
+func EventCounts() []EventCount {
+	statistics.mutex.Lock()
+	defer statistics.mutex.Unlock()
+
+	counts := make([]EventCount, 0, len(statistics.events))
+	for key, n := range statistics.events {
+		counts = append(counts, EventCount{
+			Primitive: key.primitive,
+			Name:      key.name,
+			Kind:      key.kind,
+			Count:     n,
+		})
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		a, b := counts[i], counts[j]
+		if a.Primitive != b.Primitive {
+			return a.Primitive < b.Primitive
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+
+		return a.Kind < b.Kind
+	})
+
+	return counts
+}
+
+// WaitGroupCounters returns the total counter of the wait groups per name,
+// sorted by name.
+func WaitGroupCounters() []WaitGroupCounter {
+	statistics.mutex.Lock()
+	defer statistics.mutex.Unlock()
+
+	counters := make([]WaitGroupCounter, 0, len(statistics.waitGroups))
+	for name, counter := range statistics.waitGroups {
+		counters = append(counters, WaitGroupCounter{
+			Name:    name,
+			Counter: atomic.LoadInt64(counter),
+		})
+	}
+
+	sort.Slice(counters, func(i, j int) bool { return counters[i].Name < counters[j].Name })
+
+	return counters
+}
+
+// Pools returns the statistics of the pools per name, sorted by name.
+func Pools() []PoolStats {
+	statistics.mutex.Lock()
+	defer statistics.mutex.Unlock()
+
+	pools := make([]PoolStats, 0, len(statistics.pools))
+
+	for name, stats := range statistics.pools {
+		pool := PoolStats{
+			Name:        name,
+			Hits:        atomic.LoadUint64(&stats.counters.hits),
+			Misses:      atomic.LoadUint64(&stats.counters.misses),
+			Allocations: atomic.LoadUint64(&stats.counters.allocations),
+		}
+
+		for site, n := range stats.sites {
+			pool.Outstanding += n
+			pool.Sites = append(pool.Sites, PoolSite{Site: site, Outstanding: n})
+		}
+
+		sort.Slice(pool.Sites, func(i, j int) bool {
+			a, b := pool.Sites[i], pool.Sites[j]
+			return a.Outstanding > b.Outstanding || a.Outstanding == b.Outstanding && a.Site < b.Site
+		})
+
+		pools = append(pools, pool)
+	}
+
+	sort.Slice(pools, func(i, j int) bool { return pools[i].Name < pools[j].Name })
+
+	return pools
+}
+
+// recordWait records that the lock of the given kind waited for d before
+// being acquired in the given mode from the given stack.
+func recordWait(c *config, kind, mode string, stack []byte, d time.Duration) {
+	statistics.observe(c, kind, mode, stack, true, d)
+}
+
+// recordHold records that the lock of the given kind has been released by
+// the holder e.
+func recordHold(c *config, kind string, e entry) {
+	statistics.observe(c, kind, e.mode, e.stack, false, time.Since(e.since))
+}
+
+// observe adds d to the wait or hold times of the lock, from the call site of
+// the stack.
+func (s *primitiveStatistics) observe(c *config, kind, mode string, stack []byte, wait bool, d time.Duration) {
+	key := statsKey{kind: kind, name: c.nameOr("")}
+	call := siteKey{site: callSite(stack), mode: mode}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sites := s.locks[key]
+	if sites == nil {
+		sites = make(map[siteKey]*SiteStats)
+		s.locks[key] = sites
+	}
+
+	stats := sites[call]
+	if stats == nil {
+		stats = &SiteStats{
+			Site: call.site,
+			Mode: mode,
+			Wait: newHistogram(),
+			Hold: newHistogram(),
+		}
+		sites[call] = stats
+	}
+
+	if wait {
+		stats.Wait.observe(d)
+	} else {
+		stats.Hold.observe(d)
+	}
+}
+
+// count counts the event e of a primitive configured by c.
+func (s *primitiveStatistics) count(c *config, e report.Event) {
+	key := eventKey{primitive: e.Primitive, name: c.nameOr(""), kind: e.Kind}
+
+	s.mutex.Lock()
+	s.events[key]++
+	s.mutex.Unlock()
+}
+
+// waitGroup returns the total counter of the wait groups of the given name,
+// or nil for the unnamed ones.
+func (s *primitiveStatistics) waitGroup(name string) *int64 {
+	if name == "" {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	counter := s.waitGroups[name]
+	if counter == nil {
+		counter = new(int64)
+		s.waitGroups[name] = counter
+	}
+
+	return counter
+}
+
+// pool returns the statistics of the pools of the given name, or nil for the
+// unnamed ones.
+func (s *primitiveStatistics) pool(name string) *poolStatistics {
+	if name == "" {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats := s.pools[name]
+	if stats == nil {
+		stats = &poolStatistics{sites: make(map[string]int64)}
+		s.pools[name] = stats
+	}
+
+	return stats
+}
+
+// handOut adds delta to the objects of the pool given from the call site, and
+// not put back yet.
+func (s *primitiveStatistics) handOut(pool *poolStatistics, site string, delta int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	pool.sites[site] += delta
+	if pool.sites[site] == 0 {
+		delete(pool.sites, site)
+	}
+}
+
+// callSite returns the first function of a stack given by debug.Stack which
+// does not belong to the package, with its location.
+func callSite(stack []byte) string {
+	lines := bytes.Split(stack, []byte("\n"))
+
+	// the first line is the header of the goroutine, followed by pairs of a
+	// function and its location
+	for i := 1; i+1 < len(lines); i += 2 {
+		function := string(lines[i])
+		if strings.HasPrefix(function, "runtime/debug.") ||
+			strings.HasPrefix(function, packagePrefix) {
+			continue
+		}
+
+		if end := strings.LastIndexByte(function, '('); end > 0 {
+			function = function[:end]
+		}
+
+		location := strings.TrimSpace(string(lines[i+1]))
+		if end := strings.LastIndex(location, " +0x"); end > 0 {
+			location = location[:end]
+		}
+
+		return function + " " + filepath.Base(location)
+	}
+
+	return "unknown"
+}
@@ -0,0 +1,151 @@
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"go.dedis.ch/debugtools/report"
+)
+
+func lockStatsOf(kind, name string) (LockStats, bool) {
+	for _, s := range Stats() {
+		if s.Kind == kind && s.Name == name {
+			return s, true
+		}
+	}
+
+	return LockStats{}, false
+}
+
+func TestStatsDebugOff(t *testing.T) {
+	DebugIsOn = false
+	ResetStats()
+
+	m := NewMutex(WithName("stats-off"))
+	m.Lock()
+	m.Unlock() //nolint:staticcheck // SA2001: empty critical section IGNORED !
+
+	_, found := lockStatsOf("Mutex", "stats-off")
+	if found {
+		t.Fatal("stats recorded while debugging is off")
+	}
+}
+
+func TestStatsDebugOn(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+	ResetStats()
+
+	m := NewRWMutex(WithName("stats-on"))
+
+	m.Lock()
+	done := make(chan struct{})
+	go func() {
+		m.RLock()
+		m.RUnlock()
+		close(done)
+	}()
+
+	// the lock is held for 20ms once the reader waits for it
+	for len(m.tracker.waiting(modeRLock)) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	m.Unlock()
+	<-done
+
+	stats, found := lockStatsOf("RWMutex", "stats-on")
+	if !found {
+		t.Fatalf("stats not recorded: %v", Stats())
+	}
+	if stats.Wait.Count != 2 || stats.Hold.Count != 2 {
+		t.Fatalf("unexpected counts: %d waits, %d holds", stats.Wait.Count, stats.Hold.Count)
+	}
+	if stats.Wait.Max < 20*time.Millisecond || stats.Hold.Max < 20*time.Millisecond {
+		t.Fatalf("unexpected durations: waited %v, held %v", stats.Wait.Max, stats.Hold.Max)
+	}
+	if len(stats.Sites) != 2 {
+		t.Fatalf("unexpected sites: %v", stats.Sites)
+	}
+
+	ResetStats()
+
+	_, found = lockStatsOf("RWMutex", "stats-on")
+	if found {
+		t.Fatal("stats not reset")
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram()
+	if h.Quantile(0.5) != 0 || h.Mean() != 0 {
+		t.Fatal("empty histogram not zero")
+	}
+
+	for i := 0; i < 9; i++ {
+		h.observe(3 * time.Microsecond)
+	}
+	h.observe(time.Minute)
+
+	if h.Count != 10 || h.Max != time.Minute {
+		t.Fatalf("unexpected histogram: %+v", h)
+	}
+	if h.Quantile(0.9) != 4*time.Microsecond {
+		t.Fatalf("unexpected 90th percentile: %v", h.Quantile(0.9))
+	}
+	if h.Quantile(1) != time.Minute {
+		t.Fatalf("unexpected 100th percentile: %v", h.Quantile(1))
+	}
+}
+
+func TestEventCounts(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+	ResetStats()
+
+	m := NewMutex(WithName("counted"), WithTimeout(10*time.Millisecond))
+
+	m.Lock()
+	time.AfterFunc(50*time.Millisecond, m.Unlock)
+	m.Lock()
+	m.Unlock()
+
+	counts := map[report.Kind]uint64{}
+	for _, c := range EventCounts() {
+		if c.Primitive == "Mutex" && c.Name == "counted" {
+			counts[c.Kind] = c.Count
+		}
+	}
+
+	if counts[report.KindTimeout] != 1 || counts[report.KindHoldTimeout] != 1 {
+		t.Fatalf("unexpected counts: %v", counts)
+	}
+}
+
+func TestWaitGroupCounters(t *testing.T) {
+	counter := func() int64 {
+		for _, c := range WaitGroupCounters() {
+			if c.Name == "counted" {
+				return c.Counter
+			}
+		}
+
+		return -1
+	}
+
+	a := NewWaitGroup(WithName("counted"))
+	b := NewWaitGroup(WithName("counted"))
+
+	a.Add(2)
+	b.Add(1)
+	if counter() != 3 {
+		t.Fatalf("unexpected counter: %d", counter())
+	}
+
+	a.Done()
+	a.Done()
+	b.Done()
+	if counter() != 0 {
+		t.Fatalf("unexpected counter: %d", counter())
+	}
+}
@@ -1,23 +1,142 @@
 package sync
 
 import (
+	"context"
 	"time"
+
+	"go.dedis.ch/debugtools/report"
 )
 
+// Timeout is the default duration after which a debug primitive reports that
+// it is waiting for too long. It can be overridden per instance with
+// WithTimeout.
 var Timeout = 10 * time.Second
 
-func startLockTimer(msg string, stack []byte) chan struct{} {
+// OnTimeout is the action taken when a goroutine waits on a debug primitive
+// for longer than its timeout, once the event has been reported. It can be
+// set with the environment variable SYNCACTION. Primitives held for too long
+// are only reported.
+var OnTimeout = report.ActionLog
+
+// DumpDir is the directory where all the goroutines are dumped when a timeout
+// or a lock-order cycle is reported, in which case the stacks of the
+// goroutines involved are added to the event. It can be set with the
+// environment variable SYNCDUMP, and dumping is disabled when empty.
+var DumpDir = ""
+
+// timeoutError is the cause of the cancellation of the context of a waiting
+// goroutine which has to panic.
+type timeoutError struct {
+	event report.Event
+}
+
+func (e *timeoutError) Error() string {
+	return e.event.String()
+}
+
+// startLockTimer emits the event e if the returned channel is not closed
+// before the timeout of the primitive. When owner is not nil, the goroutines
+// holding the primitive at the moment a waiter times out are added to the
+// event with its history and status, as are the ones waiting for it to be
+// done when it is held for too long. The ones holding or waiting on it are
+// kept in the dump, if any.
+func startLockTimer(c *config, e report.Event, owner *tracker) chan struct{} {
+	return startTimer(c, e, owner, func(report.Event) {})
+}
+
+// startWaitTimer is startLockTimer for a waiting goroutine, which then takes
+// the OnTimeout action in effect when it started waiting. To panic, the
+// waiting goroutine must be waiting on a context created with waitContext,
+// which is canceled with abort.
+func startWaitTimer(c *config, e report.Event, owner *tracker, abort context.CancelCauseFunc) chan struct{} {
+	action := OnTimeout
+
+	return startTimer(c, e, owner, func(e report.Event) {
+		switch action {
+		case report.ActionExit:
+			report.Exit(e)
+		case report.ActionPanic:
+			if abort != nil {
+				abort(&timeoutError{event: e})
+			}
+		}
+	})
+}
+
+// waitContext derives from ctx the context to wait on, with the function
+// aborting the wait when OnTimeout is ActionPanic. Otherwise, abort is nil.
+func waitContext(ctx context.Context) (_ context.Context, abort context.CancelCauseFunc) {
+	if OnTimeout != report.ActionPanic {
+		return ctx, nil
+	}
+
+	return context.WithCancelCause(ctx)
+}
+
+// panicOnTimeout panics with the report of the timeout if the wait on ctx
+// has been aborted by the timer of the primitive.
+func panicOnTimeout(ctx context.Context) {
+	err, ok := context.Cause(ctx).(*timeoutError)
+	if ok {
+		panic(err.Error())
+	}
+}
+
+func startTimer(c *config, e report.Event, owner *tracker, then func(report.Event)) chan struct{} {
 	done := make(chan struct{})
+	timeout := c.getTimeout()
 
-	go func(s []byte) {
+	go func() {
 		select {
-		case <-time.After(Timeout):
-			Logger.Error().Msgf("%v : %v", msg, string(s))
+		case <-time.After(timeout):
+			e.Duration = timeout
+
+			var involved []uint64
+			if owner != nil {
+				involved = owner.goroutines()
+				switch e.Kind {
+				case report.KindTimeout:
+					e.Related = owner.holding()
+					if status := owner.describe(); status != "" {
+						e.Message += " (" + status + ")"
+					}
+				case report.KindHoldTimeout:
+					// e.g. the goroutines blocked by a Once running its
+					// function
+					e.Related = owner.waiting(modeWait)
+				}
+				e.History = owner.recent()
+			}
+
+			e = withDump(c, e, involved...)
+			c.emit(e)
+			then(e)
 			return
 		case <-done:
 			return
 		}
-	}(stack)
+	}()
 
 	return done
 }
+
+// withDump dumps all the goroutines if DumpDir is set, and adds to e the
+// stacks of the goroutine of the event, of the related ones and of the given
+// ones. A failure is logged with the logger of the primitive configured by c.
+func withDump(c *config, e report.Event, ids ...uint64) report.Event {
+	if DumpDir == "" {
+		return e
+	}
+
+	ids = append(ids, e.Goroutine)
+	for _, g := range e.Related {
+		ids = append(ids, g.ID)
+	}
+
+	e, err := report.WithDump(e, DumpDir, ids)
+	if err != nil {
+		c.getLogger().Warn().Err(err).Msg("failed to dump goroutines")
+	}
+
+	return e
+}
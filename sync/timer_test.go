@@ -0,0 +1,164 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.dedis.ch/debugtools/report"
+)
+
+func setupOnTimeout(t *testing.T, action report.Action) {
+	original := OnTimeout
+	t.Cleanup(func() { OnTimeout = original })
+
+	OnTimeout = action
+}
+
+// recoverFrom runs f in a new goroutine and returns what it panicked with.
+func recoverFrom(f func()) string {
+	done := make(chan string)
+
+	go func() {
+		defer func() { done <- fmt.Sprint(recover()) }()
+		f()
+	}()
+
+	return <-done
+}
+
+func TestMutexPanicOnTimeout(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+	setupTimeout(t, 10*time.Millisecond)
+	setupOnTimeout(t, report.ActionPanic)
+
+	var m Mutex
+
+	m.Lock()
+	out := recoverFrom(m.Lock)
+	m.Unlock()
+
+	if !strings.Contains(out, "Mutex timed out when acquiring lock") {
+		t.Fatalf("unexpected panic: %s", out)
+	}
+	if !strings.Contains(out, "lock held by goroutine") {
+		t.Fatalf("holder not reported: %s", out)
+	}
+	if len(m.tracker.waiting(modeLock)) != 0 {
+		t.Fatalf("waiter not removed: %s", m.tracker.String())
+	}
+
+	// the lock is usable after the panic
+	m.Lock()
+	m.Unlock()
+}
+
+func TestRWMutexPanicOnTimeout(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+	setupTimeout(t, 10*time.Millisecond)
+	setupOnTimeout(t, report.ActionPanic)
+
+	var m RWMutex
+
+	m.Lock()
+	out := recoverFrom(m.RLock)
+	m.Unlock()
+
+	if !strings.Contains(out, "RWMutex timed out when acquiring RLock") {
+		t.Fatalf("unexpected panic: %s", out)
+	}
+
+	m.RLock()
+	out = recoverFrom(m.Lock)
+	m.RUnlock()
+
+	if !strings.Contains(out, "RWMutex timed out when acquiring lock") {
+		t.Fatalf("unexpected panic: %s", out)
+	}
+
+	m.Lock()
+	m.Unlock()
+}
+
+func TestWaitGroupPanicOnTimeout(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+	setupTimeout(t, 10*time.Millisecond)
+	setupOnTimeout(t, report.ActionPanic)
+
+	var wg WaitGroup
+
+	wg.Add(1)
+	out := recoverFrom(wg.Wait)
+	wg.Done()
+
+	if !strings.Contains(out, "WaitGroup timed out") {
+		t.Fatalf("unexpected panic: %s", out)
+	}
+}
+
+func TestLogOnTimeout(t *testing.T) {
+	DebugIsOn = true
+	l := setupLogger(t)
+	setupTimeout(t, 10*time.Millisecond)
+	setupOnTimeout(t, report.ActionLog)
+
+	var m Mutex
+
+	m.Lock()
+	time.AfterFunc(50*time.Millisecond, m.Unlock)
+	m.Lock()
+	m.Unlock()
+
+	if !strings.Contains(l.String(), "Mutex timed out when acquiring lock") {
+		t.Fatalf("timeout not reported: %s", l.String())
+	}
+}
+
+func TestDumpOnTimeout(t *testing.T) {
+	DebugIsOn = true
+	setupLogger(t)
+
+	original := DumpDir
+	DumpDir = t.TempDir()
+	defer func() { DumpDir = original }()
+
+	unrelated := make(chan struct{})
+	defer close(unrelated)
+
+	go func() { <-unrelated }()
+
+	var collector report.Collector
+	m := NewMutex(WithTimeout(10*time.Millisecond), WithReporter(&collector))
+
+	m.Lock()
+	time.AfterFunc(50*time.Millisecond, m.Unlock)
+	m.Lock()
+	m.Unlock()
+
+	var e report.Event
+	for _, event := range collector.Events() {
+		if event.Kind == report.KindTimeout {
+			e = event
+		}
+	}
+
+	if !strings.Contains(e.Goroutines, "TestDumpOnTimeout") {
+		t.Fatalf("goroutines involved not dumped: %s", e.Goroutines)
+	}
+	if strings.Contains(e.Goroutines, "TestDumpOnTimeout.func") {
+		t.Fatalf("unrelated goroutine kept: %s", e.Goroutines)
+	}
+
+	dump, err := os.ReadFile(e.DumpFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(dump), "TestDumpOnTimeout.func") {
+		t.Fatalf("full dump incomplete: %s", dump)
+	}
+}
@@ -0,0 +1,62 @@
+package sync
+
+import (
+	"context"
+	"runtime/trace"
+)
+
+// startRegion starts a runtime/trace region of the primitive name of the
+// given kind, e.g. "Mutex peers wait lock", if tracing is enabled. The
+// returned region, which may be nil, must be ended by the same goroutine with
+// endRegion.
+func startRegion(kind, name, what string) *trace.Region {
+	if !trace.IsEnabled() {
+		return nil
+	}
+
+	return trace.StartRegion(context.Background(), kind+" "+name+" "+what)
+}
+
+func endRegion(r *trace.Region) {
+	if r != nil {
+		r.End()
+	}
+}
+
+// startTask starts a runtime/trace task of the primitive name of the given
+// kind, e.g. "Mutex peers hold lock", if tracing is enabled. Unlike a region,
+// a task does not have to nest within the other ones of the goroutine, nor to
+// end on the goroutine where it started, so it can span the hold of a lock.
+// The returned task, which may be nil, must be ended with endTask.
+func startTask(kind, name, what string) *trace.Task {
+	if !trace.IsEnabled() {
+		return nil
+	}
+
+	_, task := trace.NewTask(context.Background(), kind+" "+name+" "+what)
+
+	return task
+}
+
+func endTask(t *trace.Task) {
+	if t != nil {
+		t.End()
+	}
+}
+
+// logTrace logs what happened to the primitive name of the given kind in the
+// runtime/trace, if tracing is enabled.
+func logTrace(kind, name, what string) {
+	if trace.IsEnabled() {
+		trace.Log(context.Background(), kind+" "+name, what)
+	}
+}
+
+// waitLabel describes a wait in the given mode, e.g. "wait lock".
+func waitLabel(mode string) string {
+	if mode == modeWait {
+		return modeWait
+	}
+
+	return modeWait + " " + mode
+}
@@ -0,0 +1,87 @@
+package sync
+
+import (
+	"bytes"
+	"runtime/trace"
+	"testing"
+	"time"
+)
+
+func TestTraceRegionsDebugOff(t *testing.T) {
+	DebugIsOn = false
+
+	var b bytes.Buffer
+	if err := trace.Start(&b); err != nil {
+		t.Skip("tracing already enabled:", err)
+	}
+
+	m := NewMutex(WithName("untraced"))
+	m.Lock()
+	m.Unlock() //nolint:staticcheck // SA2001: empty critical section IGNORED !
+
+	trace.Stop()
+
+	if bytes.Contains(b.Bytes(), []byte("Mutex untraced")) {
+		t.Fatal("region traced while debugging is off")
+	}
+}
+
+func TestTraceRegionsDebugOn(t *testing.T) {
+	DebugIsOn = true
+
+	var b bytes.Buffer
+	if err := trace.Start(&b); err != nil {
+		t.Skip("tracing already enabled:", err)
+	}
+
+	m := NewMutex(WithName("traced"))
+	m.Lock()
+	time.AfterFunc(time.Millisecond, m.Unlock)
+	m.Lock()
+	m.Unlock()
+
+	wg := NewWaitGroup(WithName("traced"))
+	wg.Add(1)
+	time.AfterFunc(time.Millisecond, wg.Done)
+	wg.Wait()
+
+	trace.Stop()
+
+	for _, region := range []string{"Mutex traced wait lock", "Mutex traced hold lock", "WaitGroup traced wait"} {
+		if !bytes.Contains(b.Bytes(), []byte(region)) {
+			t.Fatalf("region %q not traced", region)
+		}
+	}
+}
+
+func TestTraceHandOverHand(t *testing.T) {
+	DebugIsOn = true
+
+	var b bytes.Buffer
+	if err := trace.Start(&b); err != nil {
+		t.Skip("tracing already enabled:", err)
+	}
+
+	// the holds do not nest, and one of them ends on another goroutine
+	first := NewMutex(WithName("first"))
+	second := NewMutex(WithName("second"))
+
+	first.Lock()
+	second.Lock()
+	first.Unlock()
+
+	released := make(chan struct{})
+	go func() {
+		second.Unlock()
+		close(released)
+	}()
+	<-released
+
+	trace.Stop()
+
+	for _, task := range []string{"Mutex first hold lock", "Mutex second hold lock"} {
+		if !bytes.Contains(b.Bytes(), []byte(task)) {
+			t.Fatalf("task %q not traced", task)
+		}
+	}
+}
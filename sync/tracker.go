@@ -0,0 +1,403 @@
+package sync
+
+import (
+	"fmt"
+	"runtime/trace"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.dedis.ch/debugtools/internal/goroutine"
+	"go.dedis.ch/debugtools/registry"
+	"go.dedis.ch/debugtools/report"
+	"go.dedis.ch/debugtools/timeline"
+)
+
+// Modes in which a goroutine holds or waits on a debug primitive.
+const (
+	modeLock  = "lock"
+	modeRLock = "read lock"
+	modeWait  = "wait"
+	modeRun   = "run"
+)
+
+// entry describes a goroutine holding or waiting on a debug primitive.
+type entry struct {
+	// id identifies the entry among all the others.
+	id    uint64
+	gid   uint64
+	mode  string
+	stack []byte
+	since time.Time
+	// timer, if any, must be closed once the goroutine releases the
+	// primitive.
+	timer chan struct{}
+	// region is the trace region of the wait, and task the trace task of the
+	// hold, if tracing.
+	region *trace.Region
+	task   *trace.Task
+}
+
+// tracker keeps track of the goroutines holding or waiting on a debug
+// primitive, so that a waiter timing out can tell who is sitting on it, and
+// so that the primitive is listed in the registry while in use.
+// The zero value is ready to use.
+type tracker struct {
+	mutex   sync.Mutex
+	kind    string
+	name    string
+	status  func() string
+	holders []entry
+	waiters []entry
+	// generation is the generation of the registry the primitive was added
+	// to, or 0 if it is not in the registry.
+	generation uint64
+	// history, if any, keeps the last operations on the primitive.
+	history *report.History
+}
+
+// identify sets the kind and name of the primitive owning the tracker, and
+// the size of its history, the first time it is called. status optionally
+// describes the state of the primitive in the registry.
+func (t *tracker) identify(kind, name string, status func() string, historySize int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.name != "" {
+		return
+	}
+
+	t.kind = kind
+	t.name = name
+	t.status = status
+	t.history = report.NewHistory(historySize)
+}
+
+// record adds the operation of the goroutine gid to the history of the
+// primitive, if any.
+func (t *tracker) record(gid uint64, action string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.history != nil {
+		t.history.Add(newRecord(gid, action, goroutine.Caller(packagePrefix)))
+	}
+}
+
+// recent returns the last operations on the primitive, oldest first.
+func (t *tracker) recent() []report.Record {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.history.Records()
+}
+
+// wait records that the goroutine gid is blocked on the primitive.
+func (t *tracker) wait(gid uint64, mode string, stack []byte) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.history != nil {
+		t.history.Add(newRecord(gid, waitLabel(mode), callSite(stack)))
+	}
+
+	e := newEntry(gid, mode, stack)
+	e.region = startRegion(t.kind, t.name, waitLabel(mode))
+	t.waiters = append(t.waiters, e)
+	t.updateRegistry()
+
+	// skip 1 frame, wait itself, so that the profile starts at the method of
+	// the primitive which waits, e.g. Mutex.debugLock
+	waitingProfile.Add(e.id, 1)
+}
+
+// stopWaiting records that the goroutine gid is not blocked anymore.
+func (t *tracker) stopWaiting(gid uint64, mode string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.history != nil {
+		t.history.Add(newRecord(gid, "stop "+waitLabel(mode), goroutine.Caller(packagePrefix)))
+	}
+
+	var e entry
+	t.waiters, e = removeEntry(t.waiters, gid, mode, false)
+	t.updateRegistry()
+
+	if e.mode != "" {
+		waitingProfile.Remove(e.id)
+		endRegion(e.region)
+		t.recordSpan(e, waitLabel(e.mode))
+	}
+}
+
+// hold records that the goroutine gid acquired the primitive from the given
+// stack, and is not waiting on it anymore. The optional timer is given back
+// when the primitive is released.
+func (t *tracker) hold(gid uint64, mode string, stack []byte, timer chan struct{}) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.history != nil {
+		t.history.Add(newRecord(gid, mode, callSite(stack)))
+	}
+
+	var e entry
+	t.waiters, e = removeEntry(t.waiters, gid, mode, false)
+	if e.mode != "" {
+		waitingProfile.Remove(e.id)
+		endRegion(e.region)
+		t.recordSpan(e, waitLabel(e.mode))
+	}
+
+	e = newEntry(gid, mode, stack)
+	e.timer = timer
+	e.task = startTask(t.kind, t.name, "hold "+mode)
+	t.holders = append(t.holders, e)
+	t.updateRegistry()
+
+	// skip 1 frame, hold itself, so that the profile starts at the method of
+	// the primitive which is held, e.g. Mutex.debugLock
+	heldProfile.Add(e.id, 1)
+}
+
+// release forgets a holder in the given mode, preferably the goroutine gid,
+// and returns it. Since a lock can be released by another goroutine than the
+// one which acquired it, the oldest holder is removed if gid does not hold
+// it.
+func (t *tracker) release(gid uint64, mode string) (entry, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.history != nil {
+		t.history.Add(newRecord(gid, releaseLabel(mode), goroutine.Caller(packagePrefix)))
+	}
+
+	var e entry
+	t.holders, e = removeEntry(t.holders, gid, mode, true)
+	t.updateRegistry()
+
+	if e.mode != "" {
+		heldProfile.Remove(e.id)
+		endTask(e.task)
+		t.recordSpan(e, "hold "+e.mode)
+	}
+
+	return e, e.mode != ""
+}
+
+// recordSpan records in the timeline that the goroutine of the entry e waited
+// or held the primitive until now, as described by what.
+func (t *tracker) recordSpan(e entry, what string) {
+	if !timeline.IsOn() {
+		return
+	}
+
+	timeline.Record(timeline.Span{
+		Category:  "sync",
+		Name:      t.kind + " " + t.name + " " + what,
+		Goroutine: e.gid,
+		Start:     e.since,
+		End:       time.Now(),
+	})
+}
+
+// String describes the current holders, with their acquisition stack and
+// for how long they have been holding the primitive.
+func (t *tracker) String() string {
+	holding := t.holding()
+	if len(holding) == 0 {
+		return "lock is not held"
+	}
+
+	descriptions := make([]string, len(holding))
+	for i, g := range holding {
+		descriptions[i] = g.String()
+	}
+
+	return strings.Join(descriptions, "\n")
+}
+
+// goroutines returns the IDs of the goroutines holding or waiting on the
+// primitive.
+func (t *tracker) goroutines() []uint64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	ids := make([]uint64, 0, len(t.holders)+len(t.waiters))
+	for _, h := range t.holders {
+		ids = append(ids, h.gid)
+	}
+
+	for _, w := range t.waiters {
+		ids = append(ids, w.gid)
+	}
+
+	return ids
+}
+
+// holding returns the goroutines holding the primitive, for a report.
+func (t *tracker) holding() []report.Goroutine {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	goroutines := make([]report.Goroutine, len(t.holders))
+	for i, h := range t.holders {
+		goroutines[i] = reportGoroutine(h, h.mode+" held by")
+	}
+
+	return goroutines
+}
+
+// waiting returns the goroutines waiting on the primitive in the given mode,
+// for a report.
+func (t *tracker) waiting(mode string) []report.Goroutine {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var goroutines []report.Goroutine
+
+	for _, w := range t.waiters {
+		if w.mode == mode {
+			goroutines = append(goroutines, reportGoroutine(w, w.mode+" waited for by"))
+		}
+	}
+
+	return goroutines
+}
+
+// state returns the state of the primitive for the registry.
+func (t *tracker) state() registry.State {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	status := t.lockStatus()
+	if t.status != nil {
+		status = t.status()
+	}
+
+	return registry.State{
+		Kind:    t.kind,
+		Name:    t.name,
+		Status:  t.withWaiters(status),
+		Holders: registryGoroutines(t.holders),
+		Waiters: registryGoroutines(t.waiters),
+	}
+}
+
+// describe returns the status of a primitive which describes itself, with
+// its number of waiters, or an empty string for a lock.
+func (t *tracker) describe() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.status == nil {
+		return ""
+	}
+
+	return t.withWaiters(t.status())
+}
+
+func (t *tracker) withWaiters(status string) string {
+	if len(t.waiters) == 0 {
+		return status
+	}
+
+	return fmt.Sprintf("%s, %d waiter(s)", status, len(t.waiters))
+}
+
+func (t *tracker) lockStatus() string {
+	switch {
+	case len(t.holders) == 0:
+		return "unlocked"
+	case t.holders[0].mode == modeLock:
+		return "locked"
+	default:
+		return fmt.Sprintf("read locked by %d goroutine(s)", len(t.holders))
+	}
+}
+
+// updateRegistry adds the primitive to the registry while it is in use, and
+// removes it once it is not. It is added again if the registry forgot it
+// since. It must be called with the tracker locked.
+func (t *tracker) updateRegistry() {
+	inUse := len(t.holders) > 0 || len(t.waiters) > 0
+	generation := registry.Generation()
+
+	switch {
+	case inUse && registry.IsOn() && t.generation != generation:
+		t.generation = generation
+		registry.Add(t, t.state)
+	case !inUse && t.generation != 0:
+		registry.Remove(t)
+		t.generation = 0
+	}
+}
+
+// lastEntryID is the last identifier given to an entry.
+var lastEntryID uint64
+
+func newEntry(gid uint64, mode string, stack []byte) entry {
+	return entry{
+		id:    atomic.AddUint64(&lastEntryID, 1),
+		gid:   gid,
+		mode:  mode,
+		stack: stack,
+		since: time.Now(),
+	}
+}
+
+// removeEntry removes the entry of the goroutine gid in the given mode. If
+// there is none and anyone is set, the oldest entry in that mode is removed.
+// The removed entry is returned, or the zero entry if there is none.
+func removeEntry(entries []entry, gid uint64, mode string, anyone bool) ([]entry, entry) {
+	found := -1
+
+	for i, e := range entries {
+		if e.mode != mode {
+			continue
+		}
+
+		if e.gid == gid {
+			found = i
+			break
+		}
+
+		if anyone && found < 0 {
+			found = i
+		}
+	}
+
+	if found < 0 {
+		return entries, entry{}
+	}
+
+	e := entries[found]
+
+	return append(entries[:found], entries[found+1:]...), e
+}
+
+func reportGoroutine(e entry, role string) report.Goroutine {
+	return report.Goroutine{
+		ID:       e.gid,
+		Role:     role,
+		Duration: time.Since(e.since),
+		Stack:    string(e.stack),
+	}
+}
+
+func registryGoroutines(entries []entry) []registry.Goroutine {
+	goroutines := make([]registry.Goroutine, len(entries))
+
+	for i, e := range entries {
+		goroutines[i] = registry.Goroutine{
+			ID:    e.gid,
+			Mode:  e.mode,
+			Since: e.since,
+			Stack: string(e.stack),
+		}
+	}
+
+	return goroutines
+}
@@ -0,0 +1,117 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"go.dedis.ch/debugtools/internal/goroutine"
+)
+
+// setupTimeout is a helper function to shorten the debug timeout for the
+// duration of a test.
+func setupTimeout(t *testing.T, d time.Duration) {
+	original := Timeout
+	t.Cleanup(func() { Timeout = original })
+
+	Timeout = d
+}
+
+func TestMutexTimeoutReportsHolder(t *testing.T) {
+	DebugIsOn = true
+	l := setupLogger(t)
+	setupTimeout(t, 10*time.Millisecond)
+
+	var m Mutex
+
+	holderID := make(chan uint64)
+	release := make(chan struct{})
+	go func() {
+		m.Lock()
+		holderID <- goroutine.ID()
+		<-release
+		m.Unlock()
+	}()
+	gid := <-holderID
+
+	time.AfterFunc(50*time.Millisecond, func() { close(release) })
+	m.Lock()
+	m.Unlock() //nolint:staticcheck // SA2001: empty critical section IGNORED !
+
+	out := l.String()
+	if !strings.Contains(out, "Mutex timed out when acquiring lock") {
+		t.Fatalf("timeout not reported: %s", out)
+	}
+	if !strings.Contains(out, fmt.Sprintf("lock held by goroutine %d", gid)) {
+		t.Fatalf("holder not reported: %s", out)
+	}
+}
+
+func TestRWMutexTimeoutReportsReaders(t *testing.T) {
+	DebugIsOn = true
+	l := setupLogger(t)
+	setupTimeout(t, 10*time.Millisecond)
+
+	var m RWMutex
+
+	m.RLock()
+	release := make(chan struct{})
+	go func() {
+		m.RLock()
+		close(release)
+		time.Sleep(50 * time.Millisecond)
+		m.RUnlock()
+	}()
+	<-release
+	time.AfterFunc(50*time.Millisecond, m.RUnlock)
+
+	m.Lock()
+	m.Unlock() //nolint:staticcheck // SA2001: empty critical section IGNORED !
+
+	out := l.String()
+	if !strings.Contains(out, "RWMutex timed out when acquiring lock") {
+		t.Fatalf("timeout not reported: %s", out)
+	}
+	if strings.Count(out, "read lock held by goroutine") != 2 {
+		t.Fatalf("readers not reported: %s", out)
+	}
+}
+
+func TestTrackerRelease(t *testing.T) {
+	var tr tracker
+
+	tr.hold(1, modeRLock, nil, nil)
+	tr.hold(2, modeRLock, nil, nil)
+	tr.hold(3, modeLock, nil, nil)
+
+	tr.release(2, modeRLock)
+	tr.release(4, modeRLock)
+
+	if len(tr.holders) != 1 || tr.holders[0].gid != 3 {
+		t.Fatalf("unexpected holders: %v", tr.holders)
+	}
+
+	tr.release(3, modeLock)
+	if tr.String() != "lock is not held" {
+		t.Fatalf("unexpected description: %s", tr.String())
+	}
+}
+
+func TestTrackerStopWaiting(t *testing.T) {
+	var tr tracker
+
+	tr.wait(1, modeLock, nil)
+	tr.wait(2, modeLock, nil)
+
+	tr.stopWaiting(3, modeLock)
+	tr.hold(3, modeLock, nil, nil)
+	if len(tr.waiters) != 2 {
+		t.Fatalf("unexpected waiters: %v", tr.waiters)
+	}
+
+	tr.stopWaiting(2, modeLock)
+	if len(tr.waiters) != 1 || tr.waiters[0].gid != 1 {
+		t.Fatalf("unexpected waiters: %v", tr.waiters)
+	}
+}
@@ -1,12 +1,33 @@
 package sync
 
 import (
+	"context"
+	"fmt"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
+
+	"go.dedis.ch/debugtools/internal/goroutine"
+	"go.dedis.ch/debugtools/report"
 )
 
 type WaitGroup struct {
-	wg sync.WaitGroup
+	wg      sync.WaitGroup
+	counter int64
+	id      uint64
+	tracker tracker
+	cfg     *config
+	// outstanding is the total counter of the wait groups of the same name,
+	// or nil for the unnamed ones.
+	outstanding *int64
+}
+
+// NewWaitGroup creates a WaitGroup configured with the given options.
+// A WaitGroup created this way can be copied before its first use.
+func NewWaitGroup(opts ...Option) *WaitGroup {
+	cfg := newConfig(opts)
+
+	return &WaitGroup{cfg: cfg, outstanding: statistics.waitGroup(cfg.name)}
 }
 
 // Add adds delta, which may be negative, to the WaitGroup counter.
@@ -23,21 +44,66 @@ type WaitGroup struct {
 // new Add calls must happen after all previous Wait calls have returned.
 // See the WaitGroup example.
 func (wg *WaitGroup) Add(delta int) {
+	atomic.AddInt64(&wg.counter, int64(delta))
+
+	outstanding := wg.outstanding
+	if outstanding == nil {
+		outstanding = &unnamedOutstanding
+	}
+	atomic.AddInt64(outstanding, int64(delta))
+
+	if DebugIsOn {
+		wg.track().record(goroutine.ID(), fmt.Sprintf("add %d", delta))
+	}
+
 	wg.wg.Add(delta)
 }
 
 // Done decrements the WaitGroup counter by one.
 func (wg *WaitGroup) Done() {
-	wg.wg.Done()
+	wg.Add(-1)
 }
 
 // Wait blocks until the WaitGroup counter is zero.
 func (wg *WaitGroup) Wait() {
 	if DebugIsOn {
-		waiting := startLockTimer("WaitGroup timed out", debug.Stack())
-		wg.wg.Wait()
+		gid, stack := goroutine.ID(), debug.Stack()
+		wg.track().wait(gid, modeWait, stack)
+
+		ctx, abort := waitContext(context.Background())
+		if abort != nil {
+			defer abort(nil)
+		}
+
+		e := wg.event(report.KindTimeout, "WaitGroup timed out", gid, stack)
+		waiting := startWaitTimer(wg.cfg, e, &wg.tracker, abort)
+		// Wait cannot be tried nor undone, but acquireContext still allows to
+		// stop waiting when the timer aborts.
+		err := acquireContext(ctx, func() bool { return false }, wg.wg.Wait, func() {})
 		close(waiting)
+
+		wg.tracker.stopWaiting(gid, modeWait)
+		if err != nil {
+			panicOnTimeout(ctx)
+		}
 	} else {
 		wg.wg.Wait()
 	}
 }
+
+func (wg *WaitGroup) name() string {
+	return wg.cfg.nameOr(lockName("WaitGroup", lazyLockID(&wg.id)))
+}
+
+func (wg *WaitGroup) event(kind report.Kind, msg string, gid uint64, stack []byte) report.Event {
+	return newEvent(kind, "WaitGroup", wg.name(), msg, gid, stack)
+}
+
+func (wg *WaitGroup) track() *tracker {
+	wg.tracker.identify("WaitGroup", wg.name(), wg.status, wg.cfg.getHistorySize())
+	return &wg.tracker
+}
+
+func (wg *WaitGroup) status() string {
+	return fmt.Sprintf("counter %d", atomic.LoadInt64(&wg.counter))
+}
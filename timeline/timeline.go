@@ -0,0 +1,180 @@
+// Package timeline records when goroutines wait on, hold or are blocked on
+// the instrumented primitives of the sync and channel packages, and exports
+// the recording in the Chrome trace event format, with one track per
+// goroutine. The output can be opened with Perfetto (ui.perfetto.dev) or
+// chrome://tracing:
+//
+//	timeline.Start()
+//	defer timeline.Stop()
+//	...
+//	timeline.WriteFile("timeline.json")
+//
+// The waits and holds of the locks are only recorded while the debugging of
+// the sync package is on.
+package timeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limit is the maximum number of spans kept by a recording. The spans past
+// the limit are dropped and counted.
+var Limit = 1_000_000
+
+// Span is a period during which a goroutine waited on, held or was blocked
+// on a primitive.
+type Span struct {
+	// Category is the package of the primitive, either "sync" or "channel".
+	Category string
+	// Name describes the span, e.g. "Mutex peers hold lock".
+	Name      string
+	Goroutine uint64
+	Start     time.Time
+	End       time.Time
+}
+
+var enabled atomic.Bool
+
+var recording = struct {
+	sync.Mutex
+	start   time.Time
+	spans   []Span
+	dropped int
+}{}
+
+// Start starts a new recording, forgetting the previous one.
+func Start() {
+	recording.Lock()
+	defer recording.Unlock()
+
+	recording.start = time.Now()
+	recording.spans = nil
+	recording.dropped = 0
+
+	enabled.Store(true)
+}
+
+// Stop stops the recording, which is kept until the next Start.
+func Stop() {
+	enabled.Store(false)
+}
+
+// IsOn tells whether a recording is in progress.
+func IsOn() bool {
+	return enabled.Load()
+}
+
+// Record adds a span to the recording, if any.
+func Record(s Span) {
+	if !IsOn() {
+		return
+	}
+
+	recording.Lock()
+	defer recording.Unlock()
+
+	if len(recording.spans) >= Limit {
+		recording.dropped++
+		return
+	}
+
+	recording.spans = append(recording.spans, s)
+}
+
+// Spans returns the spans recorded so far, sorted by start time, and the
+// number of spans dropped past the Limit.
+func Spans() ([]Span, int) {
+	recording.Lock()
+	defer recording.Unlock()
+
+	spans := append([]Span(nil), recording.spans...)
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].Start.Before(spans[j].Start) })
+
+	return spans, recording.dropped
+}
+
+// traceEvent is an event of the Chrome trace event format, whose timestamps
+// and durations are in microseconds.
+type traceEvent struct {
+	Name     string            `json:"name"`
+	Category string            `json:"cat,omitempty"`
+	Phase    string            `json:"ph"`
+	TS       float64           `json:"ts"`
+	Duration float64           `json:"dur,omitempty"`
+	PID      int               `json:"pid"`
+	TID      uint64            `json:"tid"`
+	Args     map[string]string `json:"args,omitempty"`
+}
+
+// WriteJSON writes the recording in the Chrome trace event format.
+func WriteJSON(w io.Writer) error {
+	recording.Lock()
+	start := recording.start
+	recording.Unlock()
+
+	spans, dropped := Spans()
+
+	events := make([]traceEvent, 0, len(spans))
+	named := make(map[uint64]bool)
+
+	for _, s := range spans {
+		if !named[s.Goroutine] {
+			named[s.Goroutine] = true
+			events = append(events, traceEvent{
+				Name:  "thread_name",
+				Phase: "M",
+				PID:   1,
+				TID:   s.Goroutine,
+				Args:  map[string]string{"name": fmt.Sprintf("goroutine %d", s.Goroutine)},
+			})
+		}
+
+		events = append(events, traceEvent{
+			Name:     s.Name,
+			Category: s.Category,
+			Phase:    "X",
+			TS:       microseconds(s.Start.Sub(start)),
+			Duration: microseconds(s.End.Sub(s.Start)),
+			PID:      1,
+			TID:      s.Goroutine,
+		})
+	}
+
+	return json.NewEncoder(w).Encode(struct {
+		TraceEvents     []traceEvent      `json:"traceEvents"`
+		DisplayTimeUnit string            `json:"displayTimeUnit"`
+		OtherData       map[string]string `json:"otherData"`
+	}{
+		TraceEvents:     events,
+		DisplayTimeUnit: "ms",
+		OtherData:       map[string]string{"dropped": fmt.Sprint(dropped)},
+	})
+}
+
+// WriteFile writes the recording in the Chrome trace event format to the
+// file at the given path.
+func WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = WriteJSON(f)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func microseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Microsecond)
+}
@@ -0,0 +1,98 @@
+package timeline_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/debugtools/channel"
+	"go.dedis.ch/debugtools/sync"
+	"go.dedis.ch/debugtools/timeline"
+)
+
+type traceFile struct {
+	TraceEvents []struct {
+		Name  string            `json:"name"`
+		Phase string            `json:"ph"`
+		TS    float64           `json:"ts"`
+		Dur   float64           `json:"dur"`
+		TID   uint64            `json:"tid"`
+		Args  map[string]string `json:"args"`
+	} `json:"traceEvents"`
+}
+
+func TestRecordPrimitives(t *testing.T) {
+	sync.DebugIsOn = true
+	defer func() { sync.DebugIsOn = false }()
+
+	timeline.Start()
+	defer timeline.Stop()
+
+	m := sync.NewMutex(sync.WithName("peers"))
+	m.Lock()
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		m.Unlock()
+	}()
+	m.Lock()
+	m.Unlock()
+
+	c := channel.WithExpiration[int](0, channel.WithName("blocks"))
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		c.Send(0)
+	}()
+	c.Receive()
+
+	timeline.Stop()
+
+	var b bytes.Buffer
+	require.NoError(t, timeline.WriteJSON(&b))
+
+	var f traceFile
+	require.NoError(t, json.Unmarshal(b.Bytes(), &f))
+
+	durations := map[string]float64{}
+	threads := map[uint64]string{}
+
+	for _, e := range f.TraceEvents {
+		switch e.Phase {
+		case "M":
+			threads[e.TID] = e.Args["name"]
+		case "X":
+			require.NotEmpty(t, threads[e.TID], "no track for %s", e.Name)
+			durations[e.Name] = max(durations[e.Name], e.Dur)
+		}
+	}
+
+	require.GreaterOrEqual(t, durations["Mutex peers wait lock"], 5000.0)
+	require.GreaterOrEqual(t, durations["Mutex peers hold lock"], 5000.0)
+	require.GreaterOrEqual(t, durations["Timed blocks receive"], 5000.0)
+}
+
+func TestLimit(t *testing.T) {
+	original := timeline.Limit
+	timeline.Limit = 1
+	defer func() { timeline.Limit = original }()
+
+	timeline.Record(timeline.Span{Name: "ignored"})
+
+	timeline.Start()
+	defer timeline.Stop()
+
+	now := time.Now()
+	timeline.Record(timeline.Span{Name: "kept", Start: now, End: now})
+	timeline.Record(timeline.Span{Name: "dropped", Start: now, End: now})
+
+	spans, dropped := timeline.Spans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "kept", spans[0].Name)
+	require.Equal(t, 1, dropped)
+
+	path := filepath.Join(t.TempDir(), "timeline.json")
+	require.NoError(t, timeline.WriteFile(path))
+	require.FileExists(t, path)
+}